@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// bind authenticates the connection with the method returned by authMethod.
+// RequireTLS only applies when there is something to authenticate with, an
+// anonymous session sends no credentials.
+func (e *Exporter) bind(l *ldap.Conn) error {
+	method := e.authMethod()
+	if method == "none" {
+		return nil
+	}
+	if _, ok := l.TLSConnectionState(); !ok && e.module.RequireTLS {
+		return errors.New("refusing to bind over an unencrypted connection")
+	}
+	if e.passwordErr != nil {
+		return e.passwordErr
+	}
+	auth := e.module.Auth
+	switch method {
+	case "simple":
+		return l.Bind(auth.BindDn, e.password)
+	case "external":
+		return l.ExternalBind()
+	case "digest-md5":
+		u, err := url.Parse(e.ldapURI)
+		if err != nil {
+			return err
+		}
+		return l.MD5Bind(u.Hostname(), auth.BindDn, e.password)
+	default:
+		return fmt.Errorf("unsupported auth method %q", method)
+	}
+}
+
+// authMethod returns the auth method in effect. A simple bind without a bind
+// DN or password falls back to an anonymous session, as the exporter did
+// before binding was supported, and is reported as "none".
+func (e *Exporter) authMethod() string {
+	auth := e.module.Auth
+	if auth.Method == "simple" && e.passwordErr == nil && (auth.BindDn == "" || e.password == "") {
+		return "none"
+	}
+	return auth.Method
+}
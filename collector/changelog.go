@@ -0,0 +1,138 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// firstchangenumber, lastchangenumber
+//     The change numbers of the oldest and the newest entries under
+//     cn=changelog, reported by the Root DSE while the changelog is enabled.
+// ibm-slapdChangeLogMaxEntries
+//     The number of changelog entries kept before the oldest ones are pruned,
+//     set in cn=changelog,cn=Configuration. 0 means unlimited.
+
+var changelogCount = kingpin.Flag("collector.changelog.count",
+	"Count the entries under cn=changelog on every scrape, which can be slow on large changelogs.").Default("false").Bool()
+
+func init() {
+	registerCollector("changelog", defaultDisabled, func() scraper { return NewChangelogCollecter() })
+}
+
+type ChangelogCollecter struct {
+	firstChangeNumber *prometheus.Desc
+	lastChangeNumber  *prometheus.Desc
+	entries           *prometheus.Desc
+	countedEntries    *prometheus.Desc
+	maxEntries        *prometheus.Desc
+}
+
+func NewChangelogCollecter() *ChangelogCollecter {
+	return &ChangelogCollecter{
+		firstChangeNumber: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "changelog", "first_change_number"),
+			"The change number of the oldest changelog entry.",
+			nil,
+			nil),
+		lastChangeNumber: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "changelog", "last_change_number"),
+			"The change number of the newest changelog entry.",
+			nil,
+			nil),
+		entries: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "changelog", "entries"),
+			"The number of changelog entries between the first and the last change number.",
+			nil,
+			nil),
+		countedEntries: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "changelog", "counted_entries"),
+			"The number of entries found under cn=changelog.",
+			nil,
+			nil),
+		maxEntries: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "changelog", "max_entries"),
+			"The number of changelog entries kept before the oldest ones are pruned, 0 for unlimited.",
+			nil,
+			nil),
+	}
+}
+
+func (c *ChangelogCollecter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.firstChangeNumber
+	ch <- c.lastChangeNumber
+	ch <- c.entries
+	ch <- c.countedEntries
+	ch <- c.maxEntries
+}
+
+func (c *ChangelogCollecter) Scrape(sc *scrapeContext, ch chan<- prometheus.Metric) error {
+	x := sc.rootDSE
+	if x.GetEqualFoldAttributeValue("lastchangenumber") == "" {
+		sc.logger.Debug("Changelog is not enabled")
+		return nil
+	}
+	first := number(x.GetEqualFoldAttributeValue("firstchangenumber"))
+	last := number(x.GetEqualFoldAttributeValue("lastchangenumber"))
+	ch <- prometheus.MustNewConstMetric(c.firstChangeNumber, prometheus.GaugeValue, first)
+	ch <- prometheus.MustNewConstMetric(c.lastChangeNumber, prometheus.GaugeValue, last)
+	if first > 0 && last >= first {
+		ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, last-first+1)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, 0)
+	}
+
+	var errs []error
+	if err := c.scrapeMaxEntries(sc, ch); err != nil {
+		errs = append(errs, err)
+	}
+	if *changelogCount {
+		if err := c.scrapeCount(sc, ch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// scrapeMaxEntries reports the pruning limit, which only administrators may
+// read. A bind DN without access to it is not an error.
+func (c *ChangelogCollecter) scrapeMaxEntries(sc *scrapeContext, ch chan<- prometheus.Metric) error {
+	q := ldap.NewSearchRequest(
+		"cn=changelog,cn=Configuration",
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"ibm-slapdChangeLogMaxEntries"}, nil,
+	)
+	p, err := sc.search(q)
+	if ldap.IsErrorAnyOf(err, ldap.LDAPResultInsufficientAccessRights, ldap.LDAPResultNoSuchObject) {
+		sc.logger.Debug("Cannot read the changelog configuration", "err", err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error querying changelog configuration: %w", err)
+	}
+	// Entries hidden by ACLs may be left out without an error.
+	if len(p.Entries) == 0 {
+		sc.logger.Debug("Cannot read the changelog configuration")
+		return nil
+	}
+	x := p.Entries[0]
+	ch <- prometheus.MustNewConstMetric(c.maxEntries, prometheus.GaugeValue, number(x.GetEqualFoldAttributeValue("ibm-slapdChangeLogMaxEntries")))
+	return nil
+}
+
+func (c *ChangelogCollecter) scrapeCount(sc *scrapeContext, ch chan<- prometheus.Metric) error {
+	q := ldap.NewSearchRequest(
+		"cn=changelog",
+		ldap.ScopeSingleLevel, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"1.1"}, nil,
+	)
+	p, err := sc.search(q)
+	if err != nil {
+		return fmt.Errorf("error counting changelog entries: %w", err)
+	}
+	ch <- prometheus.MustNewConstMetric(c.countedEntries, prometheus.GaugeValue, float64(len(p.Entries)))
+	return nil
+}
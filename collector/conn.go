@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	promconfig "github.com/prometheus/common/config"
+)
+
+// stageError records the stage of the scrape an error happened in, so that
+// failures can be told apart by where they occurred.
+type stageError struct {
+	stage string
+	err   error
+}
+
+func (e *stageError) Error() string {
+	return fmt.Sprintf("%s: %s", e.stage, e.err)
+}
+
+func (e *stageError) Unwrap() error {
+	return e.err
+}
+
+// classify returns the stage and LDAP result code of a scrape error. Errors
+// that did not come from the LDAP client have the code "unknown".
+func classify(err error, stage string) (string, string) {
+	var se *stageError
+	if errors.As(err, &se) {
+		stage = se.stage
+	}
+	var le *ldap.Error
+	if errors.As(err, &le) {
+		return stage, strconv.Itoa(int(le.ResultCode))
+	}
+	return stage, "unknown"
+}
+
+// open dials the LDAP server and authenticates the connection.
+func (e *Exporter) open() (*ldap.Conn, error) {
+	l, err := e.dial()
+	if err != nil {
+		return nil, err
+	}
+	l.SetTimeout(e.opTimeout())
+	if err := e.bind(l); err != nil {
+		l.Close()
+		return nil, &stageError{"bind", err}
+	}
+	return l, nil
+}
+
+// opTimeout returns the timeout of the next LDAP operation, which must end by
+// the deadline of the scrape.
+func (e *Exporter) opTimeout() time.Duration {
+	timeout := e.module.Timeout
+	if deadline, ok := e.ctx.Deadline(); ok {
+		timeout = max(min(timeout, time.Until(deadline)), time.Millisecond)
+	}
+	return timeout
+}
+
+// dial connects to the LDAP server, negotiating TLS either directly for
+// ldaps:// or through StartTLS when the module asks for it.
+func (e *Exporter) dial() (*ldap.Conn, error) {
+	u, err := url.Parse(e.ldapURI)
+	if err != nil {
+		return nil, &stageError{"dial", err}
+	}
+	tlsConfig, err := promconfig.NewTLSConfig(&e.module.TLSConfig)
+	if err != nil {
+		return nil, &stageError{"tls", err}
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	dialer := &net.Dialer{Timeout: e.module.Timeout}
+	if deadline, ok := e.ctx.Deadline(); ok {
+		dialer.Deadline = deadline
+	}
+
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		l, err := ldap.DialURL(e.ldapURI, ldap.DialWithDialer(dialer))
+		if err != nil {
+			return nil, &stageError{"dial", err}
+		}
+		return l, nil
+	}
+
+	// The connection is dialed and TLS negotiated here rather than by
+	// ldap.DialURL so that TLS failures are not mistaken for the server being
+	// unreachable, and so that a server that never completes the handshake
+	// cannot stall the scrape.
+	port := u.Port()
+	if port == "" {
+		port = ldap.DefaultLdapPort
+		if u.Scheme == "ldaps" {
+			port = ldap.DefaultLdapsPort
+		}
+	}
+	c, err := dialer.DialContext(e.ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, &stageError{"dial", ldap.NewError(ldap.ErrorNetwork, err)}
+	}
+
+	if u.Scheme == "ldaps" {
+		ctx, cancel := context.WithTimeout(e.ctx, e.module.Timeout)
+		defer cancel()
+		tc := tls.Client(c, tlsConfig)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			c.Close()
+			return nil, &stageError{"tls", ldap.NewError(ldap.ErrorNetwork, err)}
+		}
+		l := ldap.NewConn(tc, true)
+		l.Start()
+		return l, nil
+	}
+
+	l := ldap.NewConn(c, false)
+	l.Start()
+	if e.module.StartTLS {
+		// StartTLS waits for the response within the timeout of the
+		// connection, but does the handshake without any.
+		timeout := e.opTimeout()
+		l.SetTimeout(timeout)
+		c.SetDeadline(time.Now().Add(timeout))
+		err := l.StartTLS(tlsConfig)
+		c.SetDeadline(time.Time{})
+		if err != nil {
+			l.Close()
+			return nil, &stageError{"tls", err}
+		}
+	}
+	return l, nil
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+func TestClassify(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		err       error
+		stage     string
+		wantStage string
+		wantCode  string
+	}{
+		{
+			name:      "plain error",
+			err:       errors.New("boom"),
+			stage:     "monitor",
+			wantStage: "monitor",
+			wantCode:  "unknown",
+		},
+		{
+			name:      "LDAP error",
+			err:       ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such object")),
+			stage:     "replication",
+			wantStage: "replication",
+			wantCode:  "32",
+		},
+		{
+			name:      "wrapped LDAP error",
+			err:       fmt.Errorf("error querying connections: %w", ldap.NewError(ldap.ErrorNetwork, errors.New("timed out"))),
+			stage:     "connections",
+			wantStage: "connections",
+			wantCode:  "200",
+		},
+		{
+			name:      "stage overrides default",
+			err:       &stageError{"bind", ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))},
+			stage:     "dial",
+			wantStage: "bind",
+			wantCode:  "49",
+		},
+		{
+			name:      "wrapped stage without LDAP error",
+			err:       fmt.Errorf("%w (next attempt in 1s)", &stageError{"tls", errors.New("bad certificate")}),
+			stage:     "dial",
+			wantStage: "tls",
+			wantCode:  "unknown",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stage, code := classify(tc.err, tc.stage)
+			if stage != tc.wantStage || code != tc.wantCode {
+				t.Errorf("classify(%v, %q) = %q, %q, want %q, %q", tc.err, tc.stage, stage, code, tc.wantStage, tc.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cn=connections,cn=monitor
+//     Lists every open connection in the multi-valued connection attribute, one
+//     value per connection, for example:
+
+//     connection=1878 : 9.48.85.31 : 2002-10-05 19:18:21 GMT : 1 : 1 : CN=ADMIN : :
+
+//     The fields are the connection ID, the client IP address, the time the
+//     connection was established, the number of operations initiated and
+//     completed, and the bind DN, empty for anonymous connections.
+
+var connectionsTop = kingpin.Flag("collector.connections.top",
+	"Number of client addresses and bind DNs reported by the connections collector, the rest are summed up as \"other\".").Default("10").Int()
+
+func init() {
+	registerCollector("connections", defaultDisabled, func() scraper { return NewConnectionsCollecter() })
+}
+
+type ConnectionsCollecter struct {
+	byClient  *prometheus.Desc
+	byBindDn  *prometheus.Desc
+	pending   *prometheus.Desc
+	oldestAge *prometheus.Desc
+}
+
+func NewConnectionsCollecter() *ConnectionsCollecter {
+	return &ConnectionsCollecter{
+		byClient: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "connections", "by_client"),
+			"The number of open connections from each client address.",
+			[]string{"client"},
+			nil),
+		byBindDn: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "connections", "by_bind_dn"),
+			"The number of open connections bound as each DN, empty for anonymous connections.",
+			[]string{"bind_dn"},
+			nil),
+		pending: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "connections", "operations_pending"),
+			"The number of operations initiated but not yet completed on the connections from each client address.",
+			[]string{"client"},
+			nil),
+		oldestAge: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "connections", "oldest_age_seconds"),
+			"How long the oldest open connection has been established.",
+			nil,
+			nil),
+	}
+}
+
+func (c *ConnectionsCollecter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.byClient
+	ch <- c.byBindDn
+	ch <- c.pending
+	ch <- c.oldestAge
+}
+
+func (c *ConnectionsCollecter) Scrape(sc *scrapeContext, ch chan<- prometheus.Metric) error {
+	q := ldap.NewSearchRequest(
+		"cn=connections,cn=monitor",
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"connection"}, nil,
+	)
+	p, err := sc.search(q)
+	if err != nil {
+		return fmt.Errorf("error querying connections: %w", err)
+	}
+	now := time.Now()
+
+	clients := map[string]float64{}
+	bindDns := map[string]float64{}
+	pending := map[string]float64{}
+	var oldest time.Time
+	for _, v := range p.Entries[0].GetAttributeValues("connection") {
+		x, ok := parseConnection(v)
+		if !ok {
+			sc.logger.Debug("Skipping malformed connection", "connection", v)
+			continue
+		}
+		clients[x.client]++
+		bindDns[x.bindDn]++
+		pending[x.client] += x.pending
+		if !x.started.IsZero() && (oldest.IsZero() || x.started.Before(oldest)) {
+			oldest = x.started
+		}
+	}
+
+	clients = top(clients, *connectionsTop)
+	for k, v := range clients {
+		ch <- prometheus.MustNewConstMetric(c.byClient, prometheus.GaugeValue, v, k)
+	}
+	for k, v := range top(bindDns, *connectionsTop) {
+		ch <- prometheus.MustNewConstMetric(c.byBindDn, prometheus.GaugeValue, v, k)
+	}
+	other := 0.0
+	for k, v := range pending {
+		if _, ok := clients[k]; ok {
+			ch <- prometheus.MustNewConstMetric(c.pending, prometheus.GaugeValue, v, k)
+		} else {
+			other += v
+		}
+	}
+	if _, ok := clients[otherLabel]; ok {
+		ch <- prometheus.MustNewConstMetric(c.pending, prometheus.GaugeValue, other, otherLabel)
+	}
+	if !oldest.IsZero() {
+		ch <- prometheus.MustNewConstMetric(c.oldestAge, prometheus.GaugeValue, now.Sub(oldest).Seconds())
+	}
+	return nil
+}
+
+type connection struct {
+	client  string
+	bindDn  string
+	pending float64
+	started time.Time
+}
+
+// parseConnection parses a value of the connection attribute. The start time
+// is left zero if it cannot be parsed.
+func parseConnection(v string) (connection, bool) {
+	f := strings.Split(strings.TrimPrefix(v, "connection="), " : ")
+	if len(f) < 6 {
+		return connection{}, false
+	}
+	x := connection{
+		client:  strings.TrimSpace(f[1]),
+		bindDn:  strings.TrimSpace(f[5]),
+		pending: max(number(f[3])-number(f[4]), 0),
+	}
+	if t, err := time.Parse(timeLayout, strings.TrimSpace(f[2])); err == nil {
+		x.started = t
+	}
+	return x, true
+}
+
+// otherLabel is the label value under which top sums up what it leaves out.
+const otherLabel = "other"
+
+// top keeps the n largest values of m and sums up the others as otherLabel,
+// so that the number of series does not grow with the number of clients.
+func top(m map[string]float64, n int) map[string]float64 {
+	if len(m) <= n {
+		return m
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if m[keys[i]] != m[keys[j]] {
+			return m[keys[i]] > m[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	r := map[string]float64{}
+	for i, k := range keys {
+		if i < n {
+			r[k] = m[k]
+		} else {
+			r[otherLabel] += m[k]
+		}
+	}
+	return r
+}
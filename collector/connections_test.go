@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseConnection(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		want connection
+		ok   bool
+	}{
+		{
+			name: "with prefix",
+			in:   "connection=1878 : 9.48.85.31 : 2002-10-05 19:18:21 GMT : 5 : 3 : CN=ADMIN : :",
+			want: connection{"9.48.85.31", "CN=ADMIN", 2, time.Date(2002, 10, 5, 19, 18, 21, 0, time.UTC)},
+			ok:   true,
+		},
+		{
+			name: "anonymous",
+			in:   "3 : 10.0.0.2 : 2026-10-17 13:00:00 GMT : 1 : 1 :  : :",
+			want: connection{"10.0.0.2", "", 0, time.Date(2026, 10, 17, 13, 0, 0, 0, time.UTC)},
+			ok:   true,
+		},
+		{
+			name: "IPv6 client",
+			in:   "4 : ::1 : 2026-10-17 13:00:00 GMT : 1 : 1 : CN=ROOT : :",
+			want: connection{"::1", "CN=ROOT", 0, time.Date(2026, 10, 17, 13, 0, 0, 0, time.UTC)},
+			ok:   true,
+		},
+		{
+			name: "more completed than initiated",
+			in:   "5 : 10.0.0.1 : 2026-10-17 13:00:00 GMT : 1 : 2 : CN=ROOT",
+			want: connection{"10.0.0.1", "CN=ROOT", 0, time.Date(2026, 10, 17, 13, 0, 0, 0, time.UTC)},
+			ok:   true,
+		},
+		{
+			name: "bad time",
+			in:   "6 : 10.0.0.1 : yesterday : x : 2 : CN=ROOT",
+			want: connection{"10.0.0.1", "CN=ROOT", 0, time.Time{}},
+			ok:   true,
+		},
+		{
+			name: "too few fields",
+			in:   "7 : 10.0.0.1 : 2026-10-17 13:00:00 GMT",
+		},
+		{
+			name: "empty",
+			in:   "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := parseConnection(tc.in)
+			if ok != tc.ok || got != tc.want {
+				t.Errorf("parseConnection(%q) = %+v, %v, want %+v, %v", tc.in, got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestTop(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   map[string]float64
+		n    int
+		want map[string]float64
+	}{
+		{
+			name: "fewer than n",
+			in:   map[string]float64{"a": 1, "b": 2},
+			n:    3,
+			want: map[string]float64{"a": 1, "b": 2},
+		},
+		{
+			name: "exactly n",
+			in:   map[string]float64{"a": 1, "b": 2},
+			n:    2,
+			want: map[string]float64{"a": 1, "b": 2},
+		},
+		{
+			name: "rest summed up as other",
+			in:   map[string]float64{"a": 1, "b": 5, "c": 2, "d": 3},
+			n:    2,
+			want: map[string]float64{"b": 5, "d": 3, "other": 3},
+		},
+		{
+			name: "ties broken by name",
+			in:   map[string]float64{"c": 1, "a": 1, "b": 1},
+			n:    1,
+			want: map[string]float64{"a": 1, "other": 2},
+		},
+		{
+			name: "zero",
+			in:   map[string]float64{"a": 1, "b": 2},
+			n:    0,
+			want: map[string]float64{"other": 3},
+		},
+		{
+			name: "empty",
+			in:   map[string]float64{},
+			n:    0,
+			want: map[string]float64{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := top(tc.in, tc.n); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("top(%v, %d) = %v, want %v", tc.in, tc.n, got, tc.want)
+			}
+		})
+	}
+}
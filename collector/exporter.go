@@ -1,110 +1,353 @@
 package collector
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/go-ldap/ldap/v3"
 	"github.com/prometheus/client_golang/prometheus"
-)
 
-type Config struct {
-	LdapURI *string
-	BindDn  *string
-	BindPw  *string
-}
+	"github.com/wfrank/ibmslapd_exporter/config"
+)
 
 type Exporter struct {
-	mutex  sync.RWMutex
+	ctx    context.Context
 	logger *slog.Logger
 
-	ldapURI string
-	bindDn  string
-	bindPw  string
+	ldapURI    string
+	moduleName string
+	module     *config.Module
+	state      *State
+
+	// The password is read once per scrape, bind_pw_file may be rewritten
+	// at any time.
+	password    string
+	passwordErr error
 
-	ldapConn   *ldap.Conn
-	collectors []prometheus.Collector
+	scrapers map[string]scraper
 
 	up             *prometheus.Desc
+	mode           *prometheus.Desc
+	backend        *prometheus.Desc
+	bindSuccess    *prometheus.Desc
 	info           *prometheus.Desc
+	namingContext  *prometheus.Desc
+	ldapVersion    *prometheus.Desc
+	configMode     *prometheus.Desc
+	proxy          *prometheus.Desc
+	features       *prometheus.Desc
+	featuresHash   *prometheus.Desc
+	tlsInfo        *prometheus.Desc
+	tlsCertExpiry  *prometheus.Desc
+	sessionAge     *prometheus.Desc
+	reconnects     *prometheus.Desc
+	scrapeDuration *prometheus.Desc
+	scrapeSuccess  *prometheus.Desc
 	scrapeFailures *prometheus.Desc
 }
 
-func NewExporter(logger *slog.Logger, config *Config) *Exporter {
+// NewExporter returns an exporter for a single scrape of the server at
+// ldapURI with the named module, which gives up once ctx is done.
+func NewExporter(ctx context.Context, logger *slog.Logger, state *State, ldapURI, moduleName string, module *config.Module) *Exporter {
 	e := &Exporter{
+		ctx:    ctx,
 		logger: logger,
 
-		ldapURI: *config.LdapURI,
-		bindDn:  *config.BindDn,
-		bindPw:  *config.BindPw,
+		ldapURI:    ldapURI,
+		moduleName: moduleName,
+		module:     module,
+		state:      state,
 
 		up: prometheus.NewDesc(
 			prometheus.BuildFQName("ibmslapd", "", "up"),
-			"Could the ibmslapd server be reached",
+			"Whether the ibmslapd server is serving data, not just answering the Root DSE.",
+			nil,
+			nil),
+		mode: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "server_mode"),
+			"The mode the ibmslapd server is running in.",
+			[]string{"mode"},
+			nil),
+		backend: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "backend_available"),
+			"Whether the database backend of the ibmslapd server is available.",
 			nil,
 			nil),
+		bindSuccess: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "bind_success"),
+			"Whether the exporter could authenticate to the ibmslapd server",
+			[]string{"method"},
+			nil),
 		info: prometheus.NewDesc(
 			prometheus.BuildFQName("ibmslapd", "", "info"),
 			"Could the ibmslapd server be reached",
 			[]string{"vendor", "version", "server_id"},
 			nil),
+		namingContext: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "naming_context_info"),
+			"The suffixes served by the ibmslapd server.",
+			[]string{"suffix"},
+			nil),
+		ldapVersion: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "supported_ldap_version"),
+			"The LDAP protocol versions supported by the ibmslapd server.",
+			[]string{"version"},
+			nil),
+		configMode: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "configuration_mode"),
+			"Whether the ibmslapd server is running in configuration-only mode.",
+			nil,
+			nil),
+		proxy: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "proxy"),
+			"Whether the ibmslapd server is a proxy server.",
+			nil,
+			nil),
+		features: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "supported_features"),
+			"The number of controls and extended operations supported by the ibmslapd server.",
+			[]string{"feature"},
+			nil),
+		featuresHash: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "supported_features_hash"),
+			"A hash of the OIDs of the controls and extended operations supported by the ibmslapd server, which changes with them.",
+			[]string{"feature"},
+			nil),
+		tlsInfo: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "tls", "info"),
+			"The TLS version and cipher suite negotiated with the ibmslapd server.",
+			[]string{"version", "cipher_suite"},
+			nil),
+		tlsCertExpiry: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "tls", "cert_not_after_seconds"),
+			"The notAfter date of each certificate in the chain presented by the ibmslapd server.",
+			[]string{"subject", "issuer", "serial_number"},
+			nil),
+		sessionAge: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "exporter", "session_age_seconds"),
+			"How long the persistent session to the ibmslapd server has been connected.",
+			nil,
+			nil),
+		reconnects: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "exporter", "session_reconnects_total"),
+			"Number of times the persistent session to the ibmslapd server was reestablished.",
+			nil,
+			nil),
+		scrapeDuration: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "scrape", "collector_duration_seconds"),
+			"Duration of a collector scrape.",
+			[]string{"collector"},
+			nil),
+		scrapeSuccess: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "scrape", "collector_success"),
+			"Whether a collector succeeded.",
+			[]string{"collector"},
+			nil),
 		scrapeFailures: prometheus.NewDesc(
 			prometheus.BuildFQName("ibmslapd", "exporter", "scrape_failures_total"),
-			"Number of errors while scraping ibmslapd.",
-			nil,
+			"Number of errors while scraping ibmslapd, by stage and LDAP result code.",
+			[]string{"stage", "code"},
 			nil),
 	}
-	e.collectors = append(e.collectors, NewMonitorCollecter(e))
-	e.collectors = append(e.collectors, NewReplicationCollecter(e))
+	e.password, e.passwordErr = module.Auth.Password()
+	e.scrapers = map[string]scraper{}
+	for name, factory := range factories {
+		if enabled(module, name) {
+			e.scrapers[name] = factory()
+		}
+	}
 
 	return e
 }
 
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- e.up
+	ch <- e.mode
+	ch <- e.backend
+	ch <- e.bindSuccess
 	ch <- e.info
+	ch <- e.namingContext
+	ch <- e.ldapVersion
+	ch <- e.configMode
+	ch <- e.proxy
+	ch <- e.features
+	ch <- e.featuresHash
+	ch <- e.tlsInfo
+	ch <- e.tlsCertExpiry
+	ch <- e.sessionAge
+	ch <- e.reconnects
+	ch <- e.scrapeDuration
+	ch <- e.scrapeSuccess
 	ch <- e.scrapeFailures
 
-	for _, v := range e.collectors {
+	for _, v := range e.scrapers {
 		v.Describe(ch)
 	}
 }
 
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	e.mutex.Lock()
-	defer e.mutex.Unlock()
+	defer e.collectFailures(ch)
 
-	l, err := ldap.DialURL(e.ldapURI)
+	sc := &scrapeContext{
+		ctx:    e.ctx,
+		logger: e.logger,
+		module: e.module,
+	}
+	var l *ldap.Conn
+	var err error
+	if e.module.Session.Persistent {
+		// /probe may require TLS where /metrics does not for the same module,
+		// their sessions are kept apart so as not to reset each other.
+		key := fmt.Sprintf("%s %s require_tls=%t", e.ldapURI, e.moduleName, e.module.RequireTLS)
+		s := e.state.session(key, e.module.Session.IdleTimeout)
+		if s.acquire(e.ctx) {
+			defer e.state.release(s)
+			// Reading the Root DSE checks whether the established connection
+			// still works, so that it is not searched twice.
+			ping := func(l *ldap.Conn) error {
+				sc.conn = l
+				return sc.readRootDSE()
+			}
+			l, err = s.connect(e.open, ping, e.module, e.opTimeout())
+		} else {
+			err = &stageError{"dial", ldap.NewError(ldap.ErrorNetwork, errors.New("timed out waiting for the persistent session"))}
+		}
+		if err == nil {
+			ch <- prometheus.MustNewConstMetric(e.sessionAge, prometheus.GaugeValue, time.Since(s.connected).Seconds())
+			ch <- prometheus.MustNewConstMetric(e.reconnects, prometheus.CounterValue, float64(s.reconnects))
+		}
+	} else {
+		l, err = e.open()
+		if err == nil {
+			defer l.Close()
+		}
+	}
 	if err != nil {
-		e.logger.Error("Error contacting LDAP server", "err", err)
+		stage := e.fail(err, "dial")
+		if stage == "bind" {
+			method := e.authMethod()
+			e.logger.Error("Error binding to LDAP server", "method", method, "bind_dn", e.module.Auth.BindDn, "err", err)
+			ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 0)
+			ch <- prometheus.MustNewConstMetric(e.bindSuccess, prometheus.GaugeValue, 0, method)
+			return
+		}
+		e.logger.Error("Error contacting LDAP server", "stage", stage, "err", err)
 		ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 0)
 		return
 	}
-	defer l.Close()
-	l.SetTimeout(1 * time.Second)
-	e.ldapConn = l
+	ch <- prometheus.MustNewConstMetric(e.bindSuccess, prometheus.GaugeValue, 1, e.authMethod())
 
-	q := ldap.NewSearchRequest(
-		"",
-		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
-		"(objectClass=*)", []string{"*"}, nil,
-	)
-	p, err := l.Search(q)
-	if err != nil {
-		e.logger.Error("Error querying Root DSE", "err", err)
-		ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 0)
-		return
+	if s, ok := l.TLSConnectionState(); ok {
+		ch <- prometheus.MustNewConstMetric(e.tlsInfo, prometheus.GaugeValue, 1,
+			tls.VersionName(s.Version), tls.CipherSuiteName(s.CipherSuite))
+		for _, c := range s.PeerCertificates {
+			ch <- prometheus.MustNewConstMetric(e.tlsCertExpiry, prometheus.GaugeValue, float64(c.NotAfter.Unix()),
+				c.Subject.String(), c.Issuer.String(), c.SerialNumber.String())
+		}
+	}
+
+	sc.conn = l
+	if sc.rootDSE == nil {
+		if err := sc.readRootDSE(); err != nil {
+			e.fail(err, "rootdse")
+			e.logger.Error("Error querying Root DSE", "err", err)
+			ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 0)
+			return
+		}
 	}
-	ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 1)
 
-	x := p.Entries[0]
+	x := sc.rootDSE
+	mode := e.serverMode(sc, x)
+	for _, v := range serverModes {
+		if v == mode {
+			ch <- prometheus.MustNewConstMetric(e.mode, prometheus.GaugeValue, 1, v)
+		} else {
+			ch <- prometheus.MustNewConstMetric(e.mode, prometheus.GaugeValue, 0, v)
+		}
+	}
+	available, probed := false, true
+	if mode != "config_only" {
+		available, probed = e.backendAvailable(sc, x)
+	}
+	if probed {
+		ch <- prometheus.MustNewConstMetric(e.backend, prometheus.GaugeValue, boolValue(available))
+	}
+	ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, boolValue(available))
+
 	id := x.GetAttributeValue("ibm-serverId")
 	vendor := x.GetAttributeValue("vendorname")
 	version := x.GetAttributeValue("vendorversion")
 	ch <- prometheus.MustNewConstMetric(e.info, prometheus.GaugeValue, 1, vendor, version, id)
+	e.collectRootDSE(ch, x)
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(e.scrapers))
+	for name, v := range e.scrapers {
+		go func() {
+			e.execute(name, v, sc, ch)
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+}
+
+// execute runs one scraper and reports how long it took and whether it
+// succeeded, so that a failing collector does not go unnoticed behind up.
+func (e *Exporter) execute(name string, c scraper, sc *scrapeContext, ch chan<- prometheus.Metric) {
+	begin := time.Now()
+	err := c.Scrape(sc, ch)
+	duration := time.Since(begin)
+	var success float64
+
+	if err != nil {
+		e.fail(err, name)
+		e.logger.Error("Error collecting metrics", "collector", name, "duration_seconds", duration.Seconds(), "err", err)
+	} else {
+		e.logger.Debug("Collector succeeded", "collector", name, "duration_seconds", duration.Seconds())
+		success = 1
+	}
+	ch <- prometheus.MustNewConstMetric(e.scrapeDuration, prometheus.GaugeValue, duration.Seconds(), name)
+	ch <- prometheus.MustNewConstMetric(e.scrapeSuccess, prometheus.GaugeValue, success, name)
+}
+
+// fail counts a scrape failure of the target and returns the stage it is
+// counted for, which defaults to the given one.
+func (e *Exporter) fail(err error, stage string) string {
+	stage, code := classify(err, stage)
+	e.state.fail(e.ldapURI, stage, code)
+	return stage
+}
+
+func (e *Exporter) collectFailures(ch chan<- prometheus.Metric) {
+	for k, v := range e.state.failuresOf(e.ldapURI) {
+		ch <- prometheus.MustNewConstMetric(e.scrapeFailures, prometheus.CounterValue, v, k.stage, k.code)
+	}
+}
+
+// ValidateConfig checks the parts of the configuration that depend on the
+// collectors known to this package.
+func ValidateConfig(c *config.Config) error {
+	for name, m := range c.Modules {
+		for _, v := range m.Collectors {
+			if _, ok := factories[v]; !ok {
+				return fmt.Errorf("module %q: unknown collector %q", name, v)
+			}
+		}
+	}
+	return nil
+}
 
-	for _, v := range e.collectors {
-		v.Collect(ch)
+// enabled reports whether the module enables the named collector. Modules
+// that do not list any collectors use the ones enabled on the command line.
+func enabled(m *config.Module, name string) bool {
+	if len(m.Collectors) == 0 {
+		return *collectorState[name]
 	}
+	return slices.Contains(m.Collectors, name)
 }
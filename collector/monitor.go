@@ -1,7 +1,10 @@
 package collector
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-ldap/ldap/v3"
 	"github.com/prometheus/client_golang/prometheus"
@@ -136,6 +139,7 @@ import (
 //     The current LDAP_DEBUG_FILE environment variable setting for the server.
 // auditinfo
 //     Contains the current audit configuration. This attribute is displayed only if the monitor search is initiated by an administrator.
+//     Its layout is not documented, so the audit configuration is read from cn=Audit,cn=Log Management,cn=Configuration instead.
 
 // en_currentregs
 //     The current number of client registrations for event notification.
@@ -157,27 +161,70 @@ import (
 // largest_workqueue_size
 //     The largest size that the work queue.
 
+// timeLayout is the year-month-day hour:minutes:seconds GMT format of the
+// times reported under cn=monitor.
+const timeLayout = "2006-1-2 15:4:5 GMT"
+
+func init() {
+	registerCollector("monitor", defaultEnabled, func() scraper { return NewMonitorCollecter() })
+}
+
 type MonitorCollecter struct {
-	exporter *Exporter
-
-	entriesSent              *prometheus.Desc
-	currentConnections       *prometheus.Desc
-	currentWorkQueueDepth    *prometheus.Desc
-	idleConnectionsClosed    *prometheus.Desc
-	autoConnectionCleanerRun *prometheus.Desc
-	workerThreads            *prometheus.Desc
-	totalConnections         *prometheus.Desc
-	operationsRequested      *prometheus.Desc
-	operationsCompleted      *prometheus.Desc
-	operationsFromSuppliers  *prometheus.Desc
-	operationsWaiting        *prometheus.Desc
-	operationsRetried        *prometheus.Desc
-	operationsDeadlocked     *prometheus.Desc
+	entriesSent                  *prometheus.Desc
+	currentConnections           *prometheus.Desc
+	currentWorkQueueDepth        *prometheus.Desc
+	idleConnectionsClosed        *prometheus.Desc
+	autoConnectionCleanerRun     *prometheus.Desc
+	workerThreads                *prometheus.Desc
+	totalConnections             *prometheus.Desc
+	operationsRequested          *prometheus.Desc
+	operationsCompleted          *prometheus.Desc
+	operationsFromSuppliers      *prometheus.Desc
+	operationsWaiting            *prometheus.Desc
+	operationsRetried            *prometheus.Desc
+	operationsDeadlocked         *prometheus.Desc
+	cacheSize                    *prometheus.Desc
+	cacheCurrent                 *prometheus.Desc
+	cacheHits                    *prometheus.Desc
+	cacheMisses                  *prometheus.Desc
+	cacheBypassLimit             *prometheus.Desc
+	transactionsRequested        *prometheus.Desc
+	transactionsCompleted        *prometheus.Desc
+	transactionsPreparedWaiting  *prometheus.Desc
+	attributeCacheHits           *prometheus.Desc
+	attributeCacheSize           *prometheus.Desc
+	attributeCacheCandidateHits  *prometheus.Desc
+	attributeCacheTotalSize      *prometheus.Desc
+	attributeCacheConfiguredSize *prometheus.Desc
+	attributeCacheAutoAdjust     *prometheus.Desc
+	attributeCacheAutoAdjustInfo *prometheus.Desc
+	startTime                    *prometheus.Desc
+	serverTime                   *prometheus.Desc
+	clockSkew                    *prometheus.Desc
+	logMessages                  *prometheus.Desc
+	auditEnabled                 *prometheus.Desc
+	auditOperation               *prometheus.Desc
+	auditFailedOnly              *prometheus.Desc
+	auditReadable                *prometheus.Desc
+	persistentSearches           *prometheus.Desc
+	persistentSearchPending      *prometheus.Desc
+	persistentSearchProcessed    *prometheus.Desc
+	persistentSearchLost         *prometheus.Desc
+	eventRegistrations           *prometheus.Desc
+	eventNotifications           *prometheus.Desc
+	traceEnabled                 *prometheus.Desc
+	traceLevel                   *prometheus.Desc
+	traceLog                     *prometheus.Desc
+	maxConnections               *prometheus.Desc
+	largestWorkQueue             *prometheus.Desc
+	maxOperationsWaiting         *prometheus.Desc
+	aclCacheEnabled              *prometheus.Desc
+	aclCacheSize                 *prometheus.Desc
+	bypassDerefAliases           *prometheus.Desc
 }
 
-func NewMonitorCollecter(e *Exporter) *MonitorCollecter {
+func NewMonitorCollecter() *MonitorCollecter {
 	return &MonitorCollecter{
-		exporter: e,
 		entriesSent: prometheus.NewDesc(
 			prometheus.BuildFQName("ibmslapd", "", "entries_sent_total"),
 			"The number of entries that are sent by the server since the server was started.",
@@ -243,6 +290,196 @@ func NewMonitorCollecter(e *Exporter) *MonitorCollecter {
 			"The number of operations in deadlock.",
 			nil,
 			nil),
+		cacheSize: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "cache_size"),
+			"The maximum number of items allowed in the cache of different kinds(filter, entry, group_members).",
+			[]string{"cache"},
+			nil),
+		cacheCurrent: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "cache_current"),
+			"The number of items currently in the cache of different kinds(filter, entry, group_members).",
+			[]string{"cache"},
+			nil),
+		cacheHits: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "cache_hits_total"),
+			"The number of lookups that were found in the cache of different kinds(filter, entry, group_members).",
+			[]string{"cache"},
+			nil),
+		cacheMisses: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "cache_misses_total"),
+			"The number of lookups that were not found in the cache of different kinds(filter, entry, group_members).",
+			[]string{"cache"},
+			nil),
+		cacheBypassLimit: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "cache_bypass_limit"),
+			"Results larger than this limit are not cached: entries returned by a search filter for the filter cache, members of a group for the group_members cache.",
+			[]string{"cache"},
+			nil),
+		transactionsRequested: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "transactions_requested_total"),
+			"The number of transaction operations requested in different phases(start, prepare, commit, rollback) since the server was started.",
+			[]string{"phase"},
+			nil),
+		transactionsCompleted: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "transactions_completed_total"),
+			"The number of transaction operations completed in different phases(start, prepare, commit, rollback) since the server was started.",
+			[]string{"phase"},
+			nil),
+		transactionsPreparedWaiting: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "transactions_prepared_waiting"),
+			"The number of transactions which are prepared and waiting for commit or rollback.",
+			nil,
+			nil),
+		attributeCacheHits: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "attribute_cache", "hits_total"),
+			"The number of times the attribute is used in a filter that could be processed by the attribute cache.",
+			[]string{"attribute"},
+			nil),
+		attributeCacheSize: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "attribute_cache", "size_bytes"),
+			"The amount of memory used for the attribute in the attribute cache.",
+			[]string{"attribute"},
+			nil),
+		attributeCacheCandidateHits: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "attribute_cache", "candidate_hits_total"),
+			"The number of times a non-cached attribute is used in a filter that could be processed by the attribute cache if it was cached, for up to ten most frequently used ones.",
+			[]string{"attribute"},
+			nil),
+		attributeCacheTotalSize: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "attribute_cache", "total_size_bytes"),
+			"The amount of memory used by attribute caching.",
+			nil,
+			nil),
+		attributeCacheConfiguredSize: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "attribute_cache", "configured_size_bytes"),
+			"The amount of memory that can be used by attribute caching.",
+			nil,
+			nil),
+		attributeCacheAutoAdjust: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "attribute_cache", "auto_adjust"),
+			"Whether attribute cache auto adjusting is configured to be on.",
+			nil,
+			nil),
+		attributeCacheAutoAdjustInfo: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "attribute_cache", "auto_adjust_info"),
+			"The configured time to start attribute cache auto adjusting and the interval after which to repeat it for the day.",
+			[]string{"time", "interval"},
+			nil),
+		startTime: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "start_time_seconds"),
+			"The time the server was started, in seconds since the epoch.",
+			nil,
+			nil),
+		serverTime: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "server_time_seconds"),
+			"The current time on the server, in seconds since the epoch.",
+			nil,
+			nil),
+		clockSkew: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "clock_skew_seconds"),
+			"The current time on the server minus the time of the exporter when the server answered, precise to about a second.",
+			nil,
+			nil),
+		logMessages: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "log_messages_total"),
+			"The number of messages recorded in different logs(error, db2_error, audit, audit_failed_op) since the server was started or since a reset was performed.",
+			[]string{"log"},
+			nil),
+		auditEnabled: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "audit", "enabled"),
+			"Whether auditing is enabled.",
+			nil,
+			nil),
+		auditOperation: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "audit", "operation_enabled"),
+			"Whether auditing is enabled for different kinds of operations(bind, unbind, search, add, modify, delete, modifydn, extop, compare).",
+			[]string{"operation"},
+			nil),
+		auditReadable: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "audit", "config_readable"),
+			"Whether the audit configuration could be read, which requires an administrator bind DN.",
+			nil,
+			nil),
+		auditFailedOnly: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "audit", "failed_operations_only"),
+			"Whether only failed operations are audited.",
+			nil,
+			nil),
+		persistentSearches: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "persistent_search", "current"),
+			"The number of active persistent search connections.",
+			nil,
+			nil),
+		persistentSearchPending: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "persistent_search", "pending_changes"),
+			"The number of updates in the queue that are yet to be processed by the persistent search thread.",
+			nil,
+			nil),
+		persistentSearchProcessed: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "persistent_search", "processed_changes_total"),
+			"The number of changes processed by the persistent search thread.",
+			nil,
+			nil),
+		persistentSearchLost: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "persistent_search", "lost_connections_total"),
+			"The number of lost persistent search connections.",
+			nil,
+			nil),
+		eventRegistrations: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "event_notification", "registrations"),
+			"The current number of client registrations for event notification.",
+			nil,
+			nil),
+		eventNotifications: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "event_notification", "sent_total"),
+			"The total number of event notifications sent to clients since the server was started.",
+			nil,
+			nil),
+		traceEnabled: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "trace", "enabled"),
+			"Whether the server is collecting trace data.",
+			nil,
+			nil),
+		traceLevel: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "trace", "message_level"),
+			"The current ldap_debug value for the server.",
+			nil,
+			nil),
+		traceLog: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "trace", "message_log_info"),
+			"The current LDAP_DEBUG_FILE environment variable setting for the server.",
+			[]string{"file"},
+			nil),
+		maxConnections: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "max_connections"),
+			"The maximum number of active connections allowed.",
+			nil,
+			nil),
+		largestWorkQueue: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "largest_work_queue_size"),
+			"The largest size that the work queue has reached.",
+			nil,
+			nil),
+		maxOperationsWaiting: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "maximum_operations_waiting"),
+			"The maximum number of operations waiting in the deadlock detector at a time.",
+			nil,
+			nil),
+		aclCacheEnabled: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "acl_cache", "enabled"),
+			"Whether the ACL cache is active.",
+			nil,
+			nil),
+		aclCacheSize: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "acl_cache", "size"),
+			"The maximum number of entries in the ACL cache.",
+			nil,
+			nil),
+		bypassDerefAliases: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "", "bypass_deref_aliases"),
+			"Whether alias processing can be bypassed because no alias object exists in the directory.",
+			nil,
+			nil),
 	}
 }
 
@@ -260,19 +497,57 @@ func (c *MonitorCollecter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.operationsWaiting
 	ch <- c.operationsRetried
 	ch <- c.operationsDeadlocked
+	ch <- c.cacheSize
+	ch <- c.cacheCurrent
+	ch <- c.cacheHits
+	ch <- c.cacheMisses
+	ch <- c.cacheBypassLimit
+	ch <- c.transactionsRequested
+	ch <- c.transactionsCompleted
+	ch <- c.transactionsPreparedWaiting
+	ch <- c.attributeCacheHits
+	ch <- c.attributeCacheSize
+	ch <- c.attributeCacheCandidateHits
+	ch <- c.attributeCacheTotalSize
+	ch <- c.attributeCacheConfiguredSize
+	ch <- c.attributeCacheAutoAdjust
+	ch <- c.attributeCacheAutoAdjustInfo
+	ch <- c.startTime
+	ch <- c.serverTime
+	ch <- c.clockSkew
+	ch <- c.logMessages
+	ch <- c.auditEnabled
+	ch <- c.auditOperation
+	ch <- c.auditFailedOnly
+	ch <- c.auditReadable
+	ch <- c.persistentSearches
+	ch <- c.persistentSearchPending
+	ch <- c.persistentSearchProcessed
+	ch <- c.persistentSearchLost
+	ch <- c.eventRegistrations
+	ch <- c.eventNotifications
+	ch <- c.traceEnabled
+	ch <- c.traceLevel
+	ch <- c.traceLog
+	ch <- c.maxConnections
+	ch <- c.largestWorkQueue
+	ch <- c.maxOperationsWaiting
+	ch <- c.aclCacheEnabled
+	ch <- c.aclCacheSize
+	ch <- c.bypassDerefAliases
 }
 
-func (c *MonitorCollecter) Collect(ch chan<- prometheus.Metric) {
+func (c *MonitorCollecter) Scrape(sc *scrapeContext, ch chan<- prometheus.Metric) error {
 	q := ldap.NewSearchRequest(
 		"cn=monitor",
 		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
 		"(objectClass=*)", []string{"*"}, nil,
 	)
-	p, err := c.exporter.ldapConn.Search(q)
+	p, err := sc.search(q)
 	if err != nil {
-		c.exporter.logger.Error("Error querying cn=monitor", "err", err)
-		return
+		return fmt.Errorf("error querying cn=monitor: %w", err)
 	}
+	now := time.Now()
 	x := p.Entries[0]
 
 	ch <- prometheus.MustNewConstMetric(c.entriesSent, prometheus.CounterValue, attr(x, "entriessent"))
@@ -309,6 +584,129 @@ func (c *MonitorCollecter) Collect(ch chan<- prometheus.Metric) {
 	ch <- prometheus.MustNewConstMetric(c.operationsWaiting, prometheus.GaugeValue, attr(x, "operations_waiting"))
 	ch <- prometheus.MustNewConstMetric(c.operationsRetried, prometheus.CounterValue, attr(x, "operations_retried"))
 	ch <- prometheus.MustNewConstMetric(c.operationsDeadlocked, prometheus.GaugeValue, attr(x, "operations_deadlocked"))
+	for _, k := range []string{"filter", "entry", "group_members"} {
+		ch <- prometheus.MustNewConstMetric(c.cacheSize, prometheus.GaugeValue, attr(x, k+"_cache_size"), k)
+		ch <- prometheus.MustNewConstMetric(c.cacheCurrent, prometheus.GaugeValue, attr(x, k+"_cache_current"), k)
+		ch <- prometheus.MustNewConstMetric(c.cacheHits, prometheus.CounterValue, attr(x, k+"_cache_hit"), k)
+		ch <- prometheus.MustNewConstMetric(c.cacheMisses, prometheus.CounterValue, attr(x, k+"_cache_miss"), k)
+	}
+	ch <- prometheus.MustNewConstMetric(c.cacheBypassLimit, prometheus.GaugeValue, attr(x, "filter_cache_bypass_limit"), "filter")
+	ch <- prometheus.MustNewConstMetric(c.cacheBypassLimit, prometheus.GaugeValue, attr(x, "group_members_cache_bypass"), "group_members")
+	for _, t := range [][3]string{
+		{"start", "transactionsrequested", "transactionscompleted"},
+		{"prepare", "transactionpreparesrequested", "transactionpreparescompleted"},
+		{"commit", "transactioncommitsrequested", "transactionscommitted"},
+		{"rollback", "transactionrollbacksrequested", "transactionsrolledback"},
+	} {
+		ch <- prometheus.MustNewConstMetric(c.transactionsRequested, prometheus.CounterValue, attr(x, t[1]), t[0])
+		ch <- prometheus.MustNewConstMetric(c.transactionsCompleted, prometheus.CounterValue, attr(x, t[2]), t[0])
+	}
+	ch <- prometheus.MustNewConstMetric(c.transactionsPreparedWaiting, prometheus.GaugeValue, attr(x, "transactionspreparedwaitingoncommit"))
+	for a, v := range attrValues(x, "cached_attribute_hit") {
+		ch <- prometheus.MustNewConstMetric(c.attributeCacheHits, prometheus.CounterValue, v, a)
+	}
+	for a, v := range attrValues(x, "cached_attribute_size") {
+		ch <- prometheus.MustNewConstMetric(c.attributeCacheSize, prometheus.GaugeValue, v*1024, a)
+	}
+	for a, v := range attrValues(x, "cached_attribute_candidate_hit") {
+		ch <- prometheus.MustNewConstMetric(c.attributeCacheCandidateHits, prometheus.CounterValue, v, a)
+	}
+	ch <- prometheus.MustNewConstMetric(c.attributeCacheTotalSize, prometheus.GaugeValue, attr(x, "cached_attribute_total_size")*1024)
+	ch <- prometheus.MustNewConstMetric(c.attributeCacheConfiguredSize, prometheus.GaugeValue, attr(x, "cached_attribute_configured_size")*1024)
+	ch <- prometheus.MustNewConstMetric(c.attributeCacheAutoAdjust, prometheus.GaugeValue, boolAttr(x, "cached_attribute_auto_adjust"))
+	ch <- prometheus.MustNewConstMetric(c.attributeCacheAutoAdjustInfo, prometheus.GaugeValue, 1,
+		x.GetAttributeValue("cached_attribute_auto_adjust_time"), x.GetAttributeValue("cached_attribute_auto_adjust_time_interval"))
+	c.collectTimes(ch, x, now)
+	for k, v := range map[string]string{
+		"error":           "slapderrorlog_messages",
+		"db2_error":       "slapdclierrors_messages",
+		"audit":           "auditlog_messages",
+		"audit_failed_op": "auditlog_failedop_messages",
+	} {
+		ch <- prometheus.MustNewConstMetric(c.logMessages, prometheus.CounterValue, attr(x, v), k)
+	}
+	ch <- prometheus.MustNewConstMetric(c.persistentSearches, prometheus.GaugeValue, attr(x, "currentpersistentsearches"))
+	ch <- prometheus.MustNewConstMetric(c.persistentSearchPending, prometheus.GaugeValue, attr(x, "persistentsearchpendingchanges"))
+	ch <- prometheus.MustNewConstMetric(c.persistentSearchProcessed, prometheus.CounterValue, attr(x, "persistentsearchprocessedchanges"))
+	ch <- prometheus.MustNewConstMetric(c.persistentSearchLost, prometheus.CounterValue, attr(x, "lostpersistentsearchconns"))
+	ch <- prometheus.MustNewConstMetric(c.eventRegistrations, prometheus.GaugeValue, attr(x, "en_currentregs"))
+	ch <- prometheus.MustNewConstMetric(c.eventNotifications, prometheus.CounterValue, attr(x, "en_notificationssent"))
+	ch <- prometheus.MustNewConstMetric(c.traceEnabled, prometheus.GaugeValue, boolAttr(x, "trace_enabled"))
+	if v, ok := debugLevel(x.GetAttributeValue("trace_message_level")); ok {
+		ch <- prometheus.MustNewConstMetric(c.traceLevel, prometheus.GaugeValue, v)
+	}
+	if v := x.GetAttributeValue("trace_message_log"); v != "" {
+		ch <- prometheus.MustNewConstMetric(c.traceLog, prometheus.GaugeValue, 1, v)
+	}
+	ch <- prometheus.MustNewConstMetric(c.maxConnections, prometheus.GaugeValue, attr(x, "maxconnections"))
+	ch <- prometheus.MustNewConstMetric(c.largestWorkQueue, prometheus.GaugeValue, attr(x, "largest_workqueue_size"))
+	ch <- prometheus.MustNewConstMetric(c.maxOperationsWaiting, prometheus.GaugeValue, attr(x, "maximum_operations_waiting"))
+	ch <- prometheus.MustNewConstMetric(c.aclCacheEnabled, prometheus.GaugeValue, boolAttr(x, "acl_cache"))
+	ch <- prometheus.MustNewConstMetric(c.aclCacheSize, prometheus.GaugeValue, attr(x, "acl_cache_size"))
+	ch <- prometheus.MustNewConstMetric(c.bypassDerefAliases, prometheus.GaugeValue, boolAttr(x, "bypass_deref_aliases"))
+	// auditinfo is only shown to administrators, who alone may read the audit
+	// configuration as well.
+	var readable bool
+	if x.GetAttributeValue("auditinfo") != "" {
+		readable, err = c.scrapeAudit(sc, ch)
+	}
+	ch <- prometheus.MustNewConstMetric(c.auditReadable, prometheus.GaugeValue, boolValue(readable))
+	return err
+}
+
+// scrapeAudit reports the audit configuration and whether it could be read,
+// which only administrators may. A bind DN without access to it is not an
+// error.
+func (c *MonitorCollecter) scrapeAudit(sc *scrapeContext, ch chan<- prometheus.Metric) (bool, error) {
+	q := ldap.NewSearchRequest(
+		"cn=Audit,cn=Log Management,cn=Configuration",
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"*"}, nil,
+	)
+	p, err := sc.search(q)
+	if ldap.IsErrorAnyOf(err, ldap.LDAPResultInsufficientAccessRights, ldap.LDAPResultNoSuchObject) {
+		sc.logger.Debug("Cannot read the audit configuration", "err", err)
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error querying audit configuration: %w", err)
+	}
+	// Entries hidden by ACLs may be left out without an error.
+	if len(p.Entries) == 0 {
+		sc.logger.Debug("Cannot read the audit configuration")
+		return false, nil
+	}
+	x := p.Entries[0]
+
+	ch <- prometheus.MustNewConstMetric(c.auditEnabled, prometheus.GaugeValue, boolAttr(x, "ibm-audit"))
+	for k, v := range map[string]string{
+		"bind":     "ibm-auditBind",
+		"unbind":   "ibm-auditUnbind",
+		"search":   "ibm-auditSearch",
+		"add":      "ibm-auditAdd",
+		"modify":   "ibm-auditModify",
+		"delete":   "ibm-auditDelete",
+		"modifydn": "ibm-auditModifyDN",
+		"extop":    "ibm-auditExtOp",
+		"compare":  "ibm-auditCompare",
+	} {
+		ch <- prometheus.MustNewConstMetric(c.auditOperation, prometheus.GaugeValue, boolAttr(x, v), k)
+	}
+	ch <- prometheus.MustNewConstMetric(c.auditFailedOnly, prometheus.GaugeValue, boolAttr(x, "ibm-auditFailedOPonly"))
+	return true, nil
+}
+
+// collectTimes reports the start time and the current time of the server,
+// and how far the latter is ahead of now. A time that cannot be parsed is
+// left out rather than reported as the epoch.
+func (c *MonitorCollecter) collectTimes(ch chan<- prometheus.Metric, x *ldap.Entry, now time.Time) {
+	if t, err := time.Parse(timeLayout, x.GetAttributeValue("starttime")); err == nil {
+		ch <- prometheus.MustNewConstMetric(c.startTime, prometheus.GaugeValue, float64(t.Unix()))
+	}
+	if t, err := time.Parse(timeLayout, x.GetAttributeValue("currenttime")); err == nil {
+		ch <- prometheus.MustNewConstMetric(c.serverTime, prometheus.GaugeValue, float64(t.Unix()))
+		ch <- prometheus.MustNewConstMetric(c.clockSkew, prometheus.GaugeValue, t.Sub(now).Seconds())
+	}
 }
 
 func plural(o string) string {
@@ -329,10 +727,54 @@ func plural(o string) string {
 }
 
 func attr(x *ldap.Entry, a string) float64 {
-	v := x.GetAttributeValue(a)
-	if f, err := strconv.ParseFloat(v, 64); err != nil {
+	return number(x.GetAttributeValue(a))
+}
+
+// number parses v as a number, or returns 0 if it is not one.
+func number(v string) float64 {
+	if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil {
 		return 0
 	} else {
 		return f
 	}
 }
+
+// boolAttr returns 1 for an attribute that reads TRUE, or on as some of the
+// cn=monitor attributes do, and 0 otherwise.
+func boolAttr(x *ldap.Entry, a string) float64 {
+	switch strings.ToLower(x.GetEqualFoldAttributeValue(a)) {
+	case "true", "on":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// attrValues parses a multi-valued attribute reported as attrname:#####,
+// such as cached_attribute_hit, into the number of each attribute name.
+func attrValues(x *ldap.Entry, a string) map[string]float64 {
+	m := map[string]float64{}
+	for _, v := range x.GetAttributeValues(a) {
+		i := strings.LastIndex(v, ":")
+		if i < 0 {
+			continue
+		}
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v[i+1:]), 64); err == nil {
+			m[strings.ToLower(strings.TrimSpace(v[:i]))] = f
+		}
+	}
+	return m
+}
+
+// debugLevel parses a debug level reported as 0xffff=65535, falling back to
+// the hexadecimal part if the decimal one is missing.
+func debugLevel(v string) (float64, bool) {
+	h, d, _ := strings.Cut(strings.TrimSpace(v), "=")
+	if n, err := strconv.ParseUint(strings.TrimSpace(d), 10, 64); err == nil {
+		return float64(n), true
+	}
+	if n, err := strconv.ParseUint(strings.TrimSpace(h), 0, 64); err == nil {
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestAttrValues(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   []string
+		want map[string]float64
+	}{
+		{
+			name: "well formed",
+			in:   []string{"cn:1234", "uid:5"},
+			want: map[string]float64{"cn": 1234, "uid": 5},
+		},
+		{
+			name: "names lowercased and spaces trimmed",
+			in:   []string{" objectClass : 7 "},
+			want: map[string]float64{"objectclass": 7},
+		},
+		{
+			name: "last colon separates the number",
+			in:   []string{"a:b:3"},
+			want: map[string]float64{"a:b": 3},
+		},
+		{
+			name: "malformed values skipped",
+			in:   []string{"nocolon", "cn:", "cn:many", ":"},
+			want: map[string]float64{},
+		},
+		{
+			name: "missing attribute",
+			want: map[string]float64{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			x := ldap.NewEntry("cn=monitor", map[string][]string{"cached_attribute_hit": tc.in})
+			if got := attrValues(x, "cached_attribute_hit"); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("attrValues(%q) = %v, want %v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDebugLevel(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"0x0=0", 0, true},
+		{"0xffff=65535", 65535, true},
+		{" 0xffff = 65535 ", 65535, true},
+		{"0xffff", 65535, true},
+		{"0xffff=", 65535, true},
+		{"0xffff=junk", 65535, true},
+		{"65535", 65535, true},
+		{"", 0, false},
+		{"junk", 0, false},
+		{"=", 0, false},
+	} {
+		got, ok := debugLevel(tc.in)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("debugLevel(%q) = %v, %v, want %v, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestCollectTimes(t *testing.T) {
+	c := NewMonitorCollecter()
+	now := time.Date(2026, 10, 17, 13, 5, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name  string
+		attrs map[string][]string
+		want  map[*prometheus.Desc]float64
+	}{
+		{
+			name: "both",
+			attrs: map[string][]string{
+				"starttime":   {"2026-10-1 3:0:0 GMT"},
+				"currenttime": {"2026-10-17 13:05:09 GMT"},
+			},
+			want: map[*prometheus.Desc]float64{
+				c.startTime:  float64(time.Date(2026, 10, 1, 3, 0, 0, 0, time.UTC).Unix()),
+				c.serverTime: float64(time.Date(2026, 10, 17, 13, 5, 9, 0, time.UTC).Unix()),
+				c.clockSkew:  9,
+			},
+		},
+		{
+			name: "server behind",
+			attrs: map[string][]string{
+				"currenttime": {"2026-10-17 13:04:58 GMT"},
+			},
+			want: map[*prometheus.Desc]float64{
+				c.serverTime: float64(time.Date(2026, 10, 17, 13, 4, 58, 0, time.UTC).Unix()),
+				c.clockSkew:  -2,
+			},
+		},
+		{
+			name: "unparsable",
+			attrs: map[string][]string{
+				"starttime":   {"20261001030000Z"},
+				"currenttime": {"2026-10-17 13:05:09"},
+			},
+			want: map[*prometheus.Desc]float64{},
+		},
+		{
+			name:  "missing",
+			attrs: map[string][]string{},
+			want:  map[*prometheus.Desc]float64{},
+		},
+	} {
+		ch := make(chan prometheus.Metric, 3)
+		c.collectTimes(ch, ldap.NewEntry("cn=monitor", tc.attrs), now)
+		close(ch)
+		got := map[*prometheus.Desc]float64{}
+		for m := range ch {
+			var d dto.Metric
+			if err := m.Write(&d); err != nil {
+				t.Fatal(err)
+			}
+			got[m.Desc()] = d.GetGauge().GetValue()
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: collectTimes() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -20,12 +21,16 @@ import (
 // ibm-replicationLastResultAdditional: N/A
 // ibm-replicationNextTime: N/A
 
+func init() {
+	registerCollector("replication", defaultEnabled, func() scraper { return NewReplicationCollecter() })
+}
+
 type ReplicationCollecter struct {
-	exporter                  *Exporter
 	state                     *prometheus.Desc
 	lastActivation            *prometheus.Desc
 	lastFinish                *prometheus.Desc
 	lastChangeId              *prometheus.Desc
+	changelogGap              *prometheus.Desc
 	pendingChanges            *prometheus.Desc
 	failedChanges             *prometheus.Desc
 	perfQueueSizeLimit        *prometheus.Desc
@@ -43,9 +48,8 @@ type ReplicationCollecter struct {
 	perfReceiverSessions      *prometheus.Desc
 }
 
-func NewReplicationCollecter(e *Exporter) *ReplicationCollecter {
+func NewReplicationCollecter() *ReplicationCollecter {
 	return &ReplicationCollecter{
-		exporter: e,
 		state: prometheus.NewDesc(
 			prometheus.BuildFQName("ibmslapd", "replication", "state"),
 			"The current state of replication with this consumer.",
@@ -66,6 +70,11 @@ func NewReplicationCollecter(e *Exporter) *ReplicationCollecter {
 			"The change ID of the last update sent to this consumer.",
 			[]string{"consumer"},
 			nil),
+		changelogGap: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "replication", "changelog_gap"),
+			"The last change number of the changelog minus the change ID of the last update sent to this consumer.",
+			[]string{"consumer"},
+			nil),
 		pendingChanges: prometheus.NewDesc(
 			prometheus.BuildFQName("ibmslapd", "replication", "pending_changes"),
 			"The number of updates queued to be replicated to this consumer.",
@@ -150,6 +159,7 @@ func (c *ReplicationCollecter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.lastActivation
 	ch <- c.lastFinish
 	ch <- c.lastChangeId
+	ch <- c.changelogGap
 	ch <- c.pendingChanges
 	ch <- c.failedChanges
 
@@ -168,18 +178,32 @@ func (c *ReplicationCollecter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.perfReceiverSessions
 }
 
-func (c *ReplicationCollecter) Collect(ch chan<- prometheus.Metric) {
-	q := ldap.NewSearchRequest(
-		"",
-		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
-		"(objectClass=ibm-replicationAgreement)", []string{"cn", "++ibmrepl"}, nil,
-	)
-	p, err := c.exporter.ldapConn.Search(q)
-	if err != nil {
-		c.exporter.logger.Error("Error querying replication agreements", "err", err)
-		return
+func (c *ReplicationCollecter) Scrape(sc *scrapeContext, ch chan<- prometheus.Metric) error {
+	baseDNs := sc.module.BaseDNs
+	if len(baseDNs) == 0 {
+		baseDNs = []string{""}
+	}
+	// The last change number is only reported while the changelog is enabled.
+	lastChange := sc.rootDSE.GetEqualFoldAttributeValue("lastchangenumber")
+	var errs []error
+	for _, b := range baseDNs {
+		q := ldap.NewSearchRequest(
+			b,
+			ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+			"(objectClass=ibm-replicationAgreement)", []string{"cn", "++ibmrepl"}, nil,
+		)
+		p, err := sc.search(q)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error querying replication agreements under %q: %w", b, err))
+			continue
+		}
+		c.collectAgreements(ch, p.Entries, lastChange)
 	}
-	for _, x := range p.Entries {
+	return errors.Join(errs...)
+}
+
+func (c *ReplicationCollecter) collectAgreements(ch chan<- prometheus.Metric, entries []*ldap.Entry, lastChange string) {
+	for _, x := range entries {
 		state := x.GetAttributeValue("ibm-replicationState")
 		if state == "" {
 			continue
@@ -206,6 +230,9 @@ func (c *ReplicationCollecter) Collect(ch chan<- prometheus.Metric) {
 			ch <- prometheus.MustNewConstMetric(c.lastFinish, prometheus.CounterValue, float64(t.Unix()), consumer)
 		}
 		ch <- prometheus.MustNewConstMetric(c.lastChangeId, prometheus.CounterValue, attr(x, "ibm-replicationLastChangeId"), consumer)
+		if lastChange != "" {
+			ch <- prometheus.MustNewConstMetric(c.changelogGap, prometheus.GaugeValue, number(lastChange)-attr(x, "ibm-replicationLastChangeId"), consumer)
+		}
 		ch <- prometheus.MustNewConstMetric(c.pendingChanges, prometheus.GaugeValue, attr(x, "ibm-replicationPendingChangeCount"), consumer)
 		ch <- prometheus.MustNewConstMetric(c.failedChanges, prometheus.GaugeValue, attr(x, "ibm-replicationFailedChangeCount"), consumer)
 
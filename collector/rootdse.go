@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"errors"
+	"hash/fnv"
+	"slices"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// namingcontexts
+//     The suffixes served by the server, including the ones it creates itself
+//     such as CN=SCHEMA and CN=LOCALHOST.
+// supportedldapversion
+//     The LDAP protocol versions the server supports.
+// ibm-slapdIsConfigurationMode
+//     TRUE if the server runs in configuration-only mode, for example because
+//     it could not connect to its database.
+// ibm-slapdServerBackend
+//     The backend of the server, PROXY for a proxy server.
+// supportedcontrol, supportedextension
+//     The OIDs of the supported controls and extended operations.
+
+// readRootDSE reads the Root DSE into sc.rootDSE for the exporter and the
+// scrapers. The change numbers of the changelog are requested by name, as
+// they are not among the attributes returned for "*".
+func (sc *scrapeContext) readRootDSE() error {
+	q := ldap.NewSearchRequest(
+		"",
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"*", "firstchangenumber", "lastchangenumber"}, nil,
+	)
+	p, err := sc.search(q)
+	if err != nil {
+		return err
+	}
+	if len(p.Entries) == 0 {
+		return ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("ldap: no Root DSE returned"))
+	}
+	sc.rootDSE = p.Entries[0]
+	return nil
+}
+
+// collectRootDSE reports what the Root DSE tells about the capabilities of
+// the server.
+func (e *Exporter) collectRootDSE(ch chan<- prometheus.Metric, x *ldap.Entry) {
+	for _, v := range x.GetEqualFoldAttributeValues("namingcontexts") {
+		ch <- prometheus.MustNewConstMetric(e.namingContext, prometheus.GaugeValue, 1, v)
+	}
+	for _, v := range x.GetEqualFoldAttributeValues("supportedldapversion") {
+		ch <- prometheus.MustNewConstMetric(e.ldapVersion, prometheus.GaugeValue, 1, v)
+	}
+	ch <- prometheus.MustNewConstMetric(e.configMode, prometheus.GaugeValue, boolAttr(x, "ibm-slapdIsConfigurationMode"))
+	if strings.EqualFold(x.GetEqualFoldAttributeValue("ibm-slapdServerBackend"), "proxy") {
+		ch <- prometheus.MustNewConstMetric(e.proxy, prometheus.GaugeValue, 1)
+	} else {
+		ch <- prometheus.MustNewConstMetric(e.proxy, prometheus.GaugeValue, 0)
+	}
+	for k, v := range map[string]string{
+		"control":   "supportedcontrol",
+		"extension": "supportedextension",
+	} {
+		oids := x.GetEqualFoldAttributeValues(v)
+		ch <- prometheus.MustNewConstMetric(e.features, prometheus.GaugeValue, float64(len(oids)), k)
+		ch <- prometheus.MustNewConstMetric(e.featuresHash, prometheus.GaugeValue, hashOIDs(oids), k)
+	}
+}
+
+// hashOIDs returns a hash of the OIDs that does not depend on their order.
+// It fits into the 53 bits that a float64 represents exactly.
+func hashOIDs(oids []string) float64 {
+	oids = slices.Clone(oids)
+	slices.Sort(oids)
+	h := fnv.New32a()
+	for _, v := range oids {
+		h.Write([]byte(strings.TrimSpace(v)))
+		h.Write([]byte{'\n'})
+	}
+	return float64(h.Sum32())
+}
+
+// serverModes are the values of the mode label of ibmslapd_server_mode.
+var serverModes = []string{"normal", "config_only", "readonly"}
+
+// serverMode returns the mode the server is running in. A server that cannot
+// reach its database starts in configuration-only mode, while a read-only
+// server is flagged by ibm-slapdReadOnly on its RDBM backend, which only
+// administrators may read.
+func (e *Exporter) serverMode(sc *scrapeContext, x *ldap.Entry) string {
+	if boolAttr(x, "ibm-slapdIsConfigurationMode") == 1 {
+		return "config_only"
+	}
+	q := ldap.NewSearchRequest(
+		"cn=Directory,cn=RDBM Backends,cn=IBM Directory,cn=Schemas,cn=Configuration",
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"ibm-slapdReadOnly"}, nil,
+	)
+	p, err := sc.search(q)
+	if err != nil {
+		e.logger.Debug("Cannot read the backend configuration", "err", err)
+		return "normal"
+	}
+	// Entries hidden by ACLs may be left out without an error.
+	if len(p.Entries) == 0 {
+		e.logger.Debug("Cannot read the backend configuration")
+		return "normal"
+	}
+	if boolAttr(p.Entries[0], "ibm-slapdReadOnly") == 1 {
+		return "readonly"
+	}
+	return "normal"
+}
+
+// backendAvailable reports whether the database backend answers a search of
+// the first of the module's base DNs or, without base DNs, of the first
+// naming context that is not one of the CN= suffixes the server keeps
+// itself. An entry that does not exist still shows that the backend answers.
+// probed is false if there is nothing to search.
+func (e *Exporter) backendAvailable(sc *scrapeContext, x *ldap.Entry) (available, probed bool) {
+	var dn string
+	if len(e.module.BaseDNs) > 0 {
+		dn = e.module.BaseDNs[0]
+	} else {
+		for _, v := range x.GetEqualFoldAttributeValues("namingcontexts") {
+			if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(v)), "CN=") {
+				dn = v
+				break
+			}
+		}
+	}
+	if dn == "" {
+		return true, false
+	}
+	q := ldap.NewSearchRequest(
+		dn,
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"1.1"}, nil,
+	)
+	if _, err := sc.search(q); err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+		e.fail(err, "backend")
+		e.logger.Error("Error searching the backend", "base_dn", dn, "err", err)
+		return false, true
+	}
+	return true, true
+}
+
+// boolValue returns 1 for true and 0 for false.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
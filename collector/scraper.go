@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/wfrank/ibmslapd_exporter/config"
+)
+
+var (
+	factories        = make(map[string]func() scraper)
+	collectorState   = make(map[string]*bool)
+	forcedCollectors = map[string]bool{} // collectors which have been explicitly enabled or disabled
+	defaultEnabled   = true
+	defaultDisabled  = false
+)
+
+// registerCollector makes a collector known by name and adds the
+// --[no-]collector.<name> flag to turn it on or off.
+func registerCollector(collector string, isDefaultEnabled bool, factory func() scraper) {
+	var helpDefaultState string
+	if isDefaultEnabled {
+		helpDefaultState = "enabled"
+	} else {
+		helpDefaultState = "disabled"
+	}
+
+	flagName := fmt.Sprintf("collector.%s", collector)
+	flagHelp := fmt.Sprintf("Enable the %s collector (default: %s).", collector, helpDefaultState)
+	defaultValue := fmt.Sprintf("%v", isDefaultEnabled)
+
+	flag := kingpin.Flag(flagName, flagHelp).Default(defaultValue).Action(collectorFlagAction(collector)).Bool()
+	collectorState[collector] = flag
+	factories[collector] = factory
+}
+
+// DisableDefaultCollectors sets the collector state to false for all
+// collectors which have not been explicitly enabled on the command line.
+func DisableDefaultCollectors() {
+	for c := range collectorState {
+		if _, ok := forcedCollectors[c]; !ok {
+			*collectorState[c] = false
+		}
+	}
+}
+
+// collectorFlagAction generates a new action function for the given
+// collector to track whether it has been explicitly enabled or disabled from
+// the command line.
+func collectorFlagAction(collector string) func(ctx *kingpin.ParseContext) error {
+	return func(ctx *kingpin.ParseContext) error {
+		forcedCollectors[collector] = true
+		return nil
+	}
+}
+
+// scraper is implemented by the collectors that query one area of the
+// server. They keep no state between scrapes and get everything they need
+// from the scrapeContext, so one instance may serve concurrent scrapes.
+type scraper interface {
+	Describe(ch chan<- *prometheus.Desc)
+	Scrape(sc *scrapeContext, ch chan<- prometheus.Metric) error
+}
+
+// scrapeContext is what a scraper needs to query the server for one scrape.
+// rootDSE is read once per scrape, before any scraper runs.
+type scrapeContext struct {
+	ctx     context.Context
+	conn    *ldap.Conn
+	logger  *slog.Logger
+	module  *config.Module
+	rootDSE *ldap.Entry
+}
+
+// search runs q and waits for its results until the operation timeout of the
+// module or the deadline of the scrape, whichever comes first.
+func (sc *scrapeContext) search(q *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	ctx, cancel := context.WithTimeout(sc.ctx, sc.module.Timeout)
+	defer cancel()
+
+	result := &ldap.SearchResult{}
+	r := sc.conn.SearchAsync(ctx, q, 0)
+	for r.Next() {
+		if x := r.Entry(); x != nil {
+			result.Entries = append(result.Entries, x)
+		}
+		if x := r.Referral(); x != "" {
+			result.Referrals = append(result.Referrals, x)
+		}
+	}
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	// The search stops without an error when the context is done.
+	if err := ctx.Err(); err != nil {
+		return nil, ldap.NewError(ldap.ErrorNetwork, errors.New("ldap: search timed out"))
+	}
+	return result, nil
+}
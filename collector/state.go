@@ -0,0 +1,239 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/wfrank/ibmslapd_exporter/config"
+)
+
+// State is kept across the exporters built for each scrape: the persistent
+// LDAP sessions, one per target and module, and the scrape failures counted
+// for each target.
+type State struct {
+	mutex    sync.Mutex
+	sessions map[string]*session
+	failures map[string]*failures
+}
+
+// failuresRetention is how long the failures of a target are kept after its
+// last scrape, so that probing arbitrary targets does not grow memory forever.
+const failuresRetention = time.Hour
+
+type failures struct {
+	counts      map[failure]float64
+	lastScraped time.Time
+}
+
+type failure struct {
+	stage string
+	code  string
+}
+
+func NewState() *State {
+	return &State{
+		sessions: map[string]*session{},
+		failures: map[string]*failures{},
+	}
+}
+
+// fail counts a scrape failure of target.
+func (s *State) fail(target, stage, code string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	f := s.failures[target]
+	if f == nil {
+		f = &failures{counts: map[failure]float64{}}
+		s.failures[target] = f
+	}
+	f.counts[failure{stage, code}]++
+	f.lastScraped = time.Now()
+}
+
+// failuresOf returns a copy of the failures counted for target. The failures
+// of targets not scraped within failuresRetention are forgotten on the way.
+func (s *State) failuresOf(target string) map[failure]float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for k, v := range s.failures {
+		if now.Sub(v.lastScraped) > failuresRetention {
+			delete(s.failures, k)
+		}
+	}
+	f := s.failures[target]
+	if f == nil {
+		return nil
+	}
+	f.lastScraped = now
+	m := make(map[failure]float64, len(f.counts))
+	for k, v := range f.counts {
+		m[k] = v
+	}
+	return m
+}
+
+type session struct {
+	// lock is held by the scrape using the session. It is a channel rather
+	// than a mutex so that waiting for it can be given up with the scrape.
+	lock chan struct{}
+
+	module     config.Module
+	conn       *ldap.Conn
+	connected  time.Time
+	reconnects int
+	failures   int
+	retryAt    time.Time
+	err        error
+
+	// guarded by State.mutex
+	lastUsed    time.Time
+	idleTimeout time.Duration
+	timer       *time.Timer
+}
+
+// session returns the session for key. Sessions that have not been used within
+// their idle timeout are closed on the way, including the one asked for, so
+// that a connection the server may have dropped meanwhile is not reused.
+func (s *State) session(key string, idleTimeout time.Duration) *session {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+	x, ok := s.sessions[key]
+	if !ok {
+		x = &session{lock: make(chan struct{}, 1)}
+		s.sessions[key] = x
+	}
+	x.lastUsed = now
+	x.idleTimeout = idleTimeout
+	return x
+}
+
+// release unlocks x after a scrape and arranges for it to be closed once it
+// has been idle for its idle timeout, even if no further scrape comes.
+func (s *State) release(x *session) {
+	s.mutex.Lock()
+	x.lastUsed = time.Now()
+	s.schedule(x, x.idleTimeout)
+	s.mutex.Unlock()
+	x.release()
+}
+
+// schedule sweeps the sessions after delay, and again for as long as x is
+// kept, for it may be in use or used again meanwhile. s.mutex must be held.
+func (s *State) schedule(x *session, delay time.Duration) {
+	if x.timer != nil {
+		x.timer.Stop()
+	}
+	x.timer = time.AfterFunc(delay, func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.sweep(time.Now())
+		for _, v := range s.sessions {
+			if v == x {
+				s.schedule(x, max(time.Until(x.lastUsed.Add(x.idleTimeout)), time.Second))
+			}
+		}
+	})
+}
+
+// sweep closes the sessions that have not been used within their idle
+// timeout and are not in use. s.mutex must be held.
+func (s *State) sweep(now time.Time) {
+	for k, v := range s.sessions {
+		if now.Sub(v.lastUsed) < v.idleTimeout || !v.tryAcquire() {
+			continue
+		}
+		v.close()
+		v.release()
+		if v.timer != nil {
+			v.timer.Stop()
+		}
+		delete(s.sessions, k)
+	}
+}
+
+// acquire locks the session, or returns false if ctx is done first.
+func (s *session) acquire(ctx context.Context) bool {
+	select {
+	case s.lock <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// tryAcquire locks the session if no scrape is using it.
+func (s *session) tryAcquire() bool {
+	select {
+	case s.lock <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *session) release() {
+	<-s.lock
+}
+
+// connect returns the established connection if ping still succeeds on it
+// within timeout, or opens a new one, which is not pinged. Failed attempts are retried with exponential
+// backoff, in between the last error is returned without contacting the
+// server. A change of the module starts the session over.
+func (s *session) connect(open func() (*ldap.Conn, error), ping func(*ldap.Conn) error, module *config.Module, timeout time.Duration) (*ldap.Conn, error) {
+	if !reflect.DeepEqual(s.module, *module) {
+		s.close()
+		s.module = *module
+		s.connected, s.reconnects = time.Time{}, 0
+		s.failures, s.retryAt, s.err = 0, time.Time{}, nil
+	}
+	maxBackoff := module.Session.MaxBackoff
+	if s.conn != nil {
+		s.conn.SetTimeout(timeout)
+		if !s.conn.IsClosing() && ping(s.conn) == nil {
+			return s.conn, nil
+		}
+		s.close()
+	}
+
+	now := time.Now()
+	if now.Before(s.retryAt) {
+		return nil, s.err
+	}
+	l, err := open()
+	if err != nil {
+		s.failures++
+		backoff := maxBackoff
+		if s.failures < 32 {
+			backoff = min(time.Second<<(s.failures-1), maxBackoff)
+		}
+		s.retryAt = now.Add(backoff)
+		s.err = fmt.Errorf("%w (next attempt in %s)", err, backoff)
+		return nil, err
+	}
+	if !s.connected.IsZero() {
+		s.reconnects++
+	}
+	s.conn = l
+	s.connected = now
+	s.failures = 0
+	s.err = nil
+	return l, nil
+}
+
+func (s *session) close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
@@ -0,0 +1,157 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/wfrank/ibmslapd_exporter/config"
+)
+
+// pipeConn returns an LDAP connection to nowhere, which closes once peer is
+// closed.
+func pipeConn(t *testing.T) (l *ldap.Conn, peer net.Conn) {
+	t.Helper()
+	c, peer := net.Pipe()
+	l = ldap.NewConn(c, false)
+	l.Start()
+	t.Cleanup(func() {
+		l.Close()
+		peer.Close()
+	})
+	return l, peer
+}
+
+// waitClosing waits for l to notice that its peer has gone.
+func waitClosing(t *testing.T, l *ldap.Conn) {
+	t.Helper()
+	for i := 0; i < 100 && !l.IsClosing(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !l.IsClosing() {
+		t.Fatal("connection not closed")
+	}
+}
+
+// ok is a ping that always succeeds.
+func ok(*ldap.Conn) error { return nil }
+
+func TestSessionConnectBackoff(t *testing.T) {
+	module := config.DefaultModule
+	module.Session.MaxBackoff = 4 * time.Second
+	s := &session{lock: make(chan struct{}, 1)}
+
+	var opened int
+	fail := func() (*ldap.Conn, error) {
+		opened++
+		return nil, errors.New("connection refused")
+	}
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		before := time.Now()
+		if _, err := s.connect(fail, ok, &module, time.Second); err == nil {
+			t.Fatalf("attempt %d: expected an error", i+1)
+		}
+		if opened != i+1 {
+			t.Fatalf("attempt %d: opened %d times", i+1, opened)
+		}
+		if backoff := s.retryAt.Sub(before); backoff < want || backoff > want+time.Second {
+			t.Errorf("attempt %d: backoff %s, want %s", i+1, backoff, want)
+		}
+
+		// Within the backoff, the last error is returned without dialing.
+		_, err := s.connect(fail, ok, &module, time.Second)
+		if err == nil || !strings.Contains(err.Error(), "next attempt in") {
+			t.Errorf("attempt %d: got %v during backoff", i+1, err)
+		}
+		if opened != i+1 {
+			t.Errorf("attempt %d: dialed during backoff", i+1)
+		}
+		s.retryAt = time.Time{}
+	}
+
+	l, _ := pipeConn(t)
+	got, err := s.connect(func() (*ldap.Conn, error) { return l, nil }, ok, &module, time.Second)
+	if err != nil || got != l {
+		t.Fatalf("connect = %v, %v, want the new connection", got, err)
+	}
+	if s.failures != 0 || s.err != nil || s.reconnects != 0 {
+		t.Errorf("failures %d, err %v, reconnects %d after connecting", s.failures, s.err, s.reconnects)
+	}
+}
+
+func TestSessionConnectReconnects(t *testing.T) {
+	module := config.DefaultModule
+	s := &session{lock: make(chan struct{}, 1)}
+
+	first, peer := pipeConn(t)
+	if _, err := s.connect(func() (*ldap.Conn, error) { return first, nil }, ok, &module, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// A connection that still answers is kept.
+	got, err := s.connect(func() (*ldap.Conn, error) { t.Fatal("reopened"); return nil, nil }, ok, &module, time.Second)
+	if err != nil || got != first {
+		t.Fatalf("connect = %v, %v, want the first connection", got, err)
+	}
+
+	// A connection the server dropped is replaced.
+	peer.Close()
+	waitClosing(t, first)
+	second, _ := pipeConn(t)
+	got, err = s.connect(func() (*ldap.Conn, error) { return second, nil }, ok, &module, time.Second)
+	if err != nil || got != second {
+		t.Fatalf("connect = %v, %v, want the second connection", got, err)
+	}
+	if s.reconnects != 1 {
+		t.Errorf("reconnects = %d, want 1", s.reconnects)
+	}
+
+	// So is one that fails the ping.
+	third, _ := pipeConn(t)
+	fail := func(*ldap.Conn) error { return errors.New("broken pipe") }
+	got, err = s.connect(func() (*ldap.Conn, error) { return third, nil }, fail, &module, time.Second)
+	if err != nil || got != third {
+		t.Fatalf("connect = %v, %v, want the third connection", got, err)
+	}
+	if s.reconnects != 2 {
+		t.Errorf("reconnects = %d, want 2", s.reconnects)
+	}
+
+	// A changed module starts the session over.
+	module.Timeout = 2 * time.Second
+	fourth, _ := pipeConn(t)
+	got, err = s.connect(func() (*ldap.Conn, error) { return fourth, nil }, ok, &module, time.Second)
+	if err != nil || got != fourth {
+		t.Fatalf("connect = %v, %v, want the fourth connection", got, err)
+	}
+	if s.reconnects != 0 {
+		t.Errorf("reconnects = %d after a module change, want 0", s.reconnects)
+	}
+	if !third.IsClosing() {
+		t.Error("connection of the old module not closed")
+	}
+}
+
+func TestStateClosesIdleSessions(t *testing.T) {
+	st := NewState()
+	x := st.session("ldap://localhost default", 20*time.Millisecond)
+	if !x.acquire(context.Background()) {
+		t.Fatal("cannot acquire session")
+	}
+	l, _ := pipeConn(t)
+	x.conn = l
+	st.release(x)
+
+	// No further scrape comes, the session is closed all the same.
+	waitClosing(t, l)
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	if len(st.sessions) != 0 {
+		t.Errorf("%d sessions kept, want 0", len(st.sessions))
+	}
+}
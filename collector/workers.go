@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cn=workers,cn=monitor
+//     Lists what every worker thread is doing in the multi-valued thread
+//     attribute, one value per worker, for example:
+
+//     thread=3334 : 9.48.85.32 : 2006-05-05 11:33:32 GMT : 12 : Search : cn=user1,o=sample : (objectclass=*)
+
+//     The fields are the thread ID, the client IP address, the time the
+//     current operation started, the connection ID, the operation type and its
+//     target DN, followed by operation specific details. Idle workers do not
+//     report an operation.
+
+var workersStuckThreshold = kingpin.Flag("collector.workers.stuck_threshold",
+	"How long a worker may run an operation before the workers collector reports it as stuck.").Default("5m").Duration()
+
+// workersAgeBuckets are the upper bounds in seconds of the operation age histogram.
+var workersAgeBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900}
+
+func init() {
+	registerCollector("workers", defaultDisabled, func() scraper { return NewWorkersCollecter() })
+}
+
+type WorkersCollecter struct {
+	operationAge *prometheus.Desc
+	stuck        *prometheus.Desc
+}
+
+func NewWorkersCollecter() *WorkersCollecter {
+	return &WorkersCollecter{
+		operationAge: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "workers", "operation_age_seconds"),
+			"How long the worker threads have been running their current operations.",
+			nil,
+			nil),
+		stuck: prometheus.NewDesc(
+			prometheus.BuildFQName("ibmslapd", "workers", "stuck"),
+			"The number of worker threads running their current operation for longer than the stuck threshold.",
+			nil,
+			nil),
+	}
+}
+
+func (c *WorkersCollecter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.operationAge
+	ch <- c.stuck
+}
+
+func (c *WorkersCollecter) Scrape(sc *scrapeContext, ch chan<- prometheus.Metric) error {
+	q := ldap.NewSearchRequest(
+		"cn=workers,cn=monitor",
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"thread"}, nil,
+	)
+	p, err := sc.search(q)
+	if err != nil {
+		return fmt.Errorf("error querying workers: %w", err)
+	}
+	now := time.Now()
+
+	var count uint64
+	var sum float64
+	buckets := map[float64]uint64{}
+	for _, b := range workersAgeBuckets {
+		buckets[b] = 0
+	}
+	var stuck float64
+	for _, v := range p.Entries[0].GetAttributeValues("thread") {
+		f := strings.Split(strings.TrimPrefix(v, "thread="), " : ")
+		if len(f) < 5 {
+			continue
+		}
+		t, err := time.Parse(timeLayout, strings.TrimSpace(f[2]))
+		if err != nil {
+			continue
+		}
+		age := max(now.Sub(t), 0)
+		count++
+		sum += age.Seconds()
+		for _, b := range workersAgeBuckets {
+			if age.Seconds() <= b {
+				buckets[b]++
+			}
+		}
+		if age > *workersStuckThreshold {
+			stuck++
+		}
+	}
+
+	ch <- prometheus.MustNewConstHistogram(c.operationAge, count, sum, buckets)
+	ch <- prometheus.MustNewConstMetric(c.stuck, prometheus.GaugeValue, stuck)
+	return nil
+}
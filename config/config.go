@@ -0,0 +1,201 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	promconfig "github.com/prometheus/common/config"
+	"gopkg.in/yaml.v2"
+)
+
+// AuthMethods lists the supported ways to authenticate the LDAP connection.
+var AuthMethods = []string{"none", "simple", "external", "digest-md5"}
+
+// DefaultModule holds the settings of a module that are not given explicitly.
+var DefaultModule = Module{
+	Auth: Auth{
+		Method: "simple",
+	},
+	Timeout: time.Second,
+	Session: Session{
+		IdleTimeout: 5 * time.Minute,
+		MaxBackoff:  time.Minute,
+	},
+}
+
+type Config struct {
+	Modules map[string]Module `yaml:"modules"`
+}
+
+// SafeConfig guards the configuration so that it can be swapped on reload
+// while scrapes keep using the module they started with.
+type SafeConfig struct {
+	sync.RWMutex
+	C *Config
+}
+
+type Module struct {
+	Auth          Auth                 `yaml:"auth,omitempty"`
+	TLSConfig     promconfig.TLSConfig `yaml:"tls_config,omitempty"`
+	StartTLS      bool                 `yaml:"start_tls,omitempty"`
+	RequireTLS    bool                 `yaml:"require_tls,omitempty"`
+	Timeout       time.Duration        `yaml:"timeout,omitempty"`
+	ScrapeTimeout time.Duration        `yaml:"scrape_timeout,omitempty"`
+	Session       Session              `yaml:"session,omitempty"`
+	Collectors    []string             `yaml:"collectors,omitempty"`
+	BaseDNs       []string             `yaml:"base_dns,omitempty"`
+}
+
+type Auth struct {
+	Method     string            `yaml:"method,omitempty"`
+	BindDn     string            `yaml:"bind_dn,omitempty"`
+	BindPw     promconfig.Secret `yaml:"bind_pw,omitempty"`
+	BindPwFile string            `yaml:"bind_pw_file,omitempty"`
+	BindPwEnv  string            `yaml:"bind_pw_env,omitempty"`
+}
+
+// Session configures a long-lived connection reused across scrapes instead of
+// dialing the server on every scrape.
+type Session struct {
+	Persistent  bool          `yaml:"persistent,omitempty"`
+	IdleTimeout time.Duration `yaml:"idle_timeout,omitempty"`
+	MaxBackoff  time.Duration `yaml:"max_backoff,omitempty"`
+}
+
+// Password returns the bind password from whichever source is configured.
+// The file is read on every call so that mounted secrets can be rotated.
+func (a *Auth) Password() (string, error) {
+	switch {
+	case a.BindPwFile != "":
+		content, err := os.ReadFile(a.BindPwFile)
+		if err != nil {
+			return "", fmt.Errorf("error reading bind password file: %s", err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	case a.BindPwEnv != "":
+		return os.Getenv(a.BindPwEnv), nil
+	default:
+		return string(a.BindPw), nil
+	}
+}
+
+// LoadFile reads and validates the configuration file. The given module is
+// used as the "default" module unless the file defines one of that name.
+func LoadFile(confFile string, defaultModule Module) (*Config, error) {
+	c := &Config{}
+	if confFile != "" {
+		content, err := os.ReadFile(confFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config file: %s", err)
+		}
+		if err := yaml.UnmarshalStrict(content, c); err != nil {
+			return nil, fmt.Errorf("error parsing config file: %s", err)
+		}
+	}
+	if c.Modules == nil {
+		c.Modules = map[string]Module{}
+	}
+	for name, m := range c.Modules {
+		m.TLSConfig.SetDirectory(filepath.Dir(confFile))
+		m.Auth.BindPwFile = promconfig.JoinDir(filepath.Dir(confFile), m.Auth.BindPwFile)
+		if err := m.validate(); err != nil {
+			return nil, fmt.Errorf("invalid module %q: %s", name, err)
+		}
+		c.Modules[name] = m
+	}
+	if _, ok := c.Modules["default"]; !ok {
+		if err := defaultModule.validate(); err != nil {
+			return nil, fmt.Errorf("invalid default module: %s", err)
+		}
+		c.Modules["default"] = defaultModule
+	}
+	return c, nil
+}
+
+// Set replaces the current configuration.
+func (sc *SafeConfig) Set(c *Config) {
+	sc.Lock()
+	sc.C = c
+	sc.Unlock()
+}
+
+// Module returns the named module of the current configuration.
+func (sc *SafeConfig) Module(name string) (*Module, bool) {
+	sc.RLock()
+	defer sc.RUnlock()
+	m, ok := sc.C.Modules[name]
+	return &m, ok
+}
+
+func (s *Module) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = DefaultModule
+	type plain Module
+	return unmarshal((*plain)(s))
+}
+
+func (s *Module) validate() error {
+	if !slices.Contains(AuthMethods, s.Auth.Method) {
+		return fmt.Errorf("unsupported auth method %q", s.Auth.Method)
+	}
+	n := 0
+	for _, v := range []string{string(s.Auth.BindPw), s.Auth.BindPwFile, s.Auth.BindPwEnv} {
+		if v != "" {
+			n++
+		}
+	}
+	if n > 1 {
+		return errors.New("at most one of bind_pw, bind_pw_file and bind_pw_env must be configured")
+	}
+	pw, err := s.Auth.Password()
+	if err != nil {
+		return err
+	}
+	if s.Auth.Method == "digest-md5" && pw == "" {
+		return errors.New("auth method digest-md5 requires a bind password")
+	}
+	if _, err := promconfig.NewTLSConfig(&s.TLSConfig); err != nil {
+		return err
+	}
+	if s.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive, got %s", s.Timeout)
+	}
+	if s.ScrapeTimeout < 0 {
+		return fmt.Errorf("scrape_timeout must not be negative, got %s", s.ScrapeTimeout)
+	}
+	if s.Session.IdleTimeout <= 0 {
+		return fmt.Errorf("session idle_timeout must be positive, got %s", s.Session.IdleTimeout)
+	}
+	if s.Session.MaxBackoff <= 0 {
+		return fmt.Errorf("session max_backoff must be positive, got %s", s.Session.MaxBackoff)
+	}
+	return validateBaseDNs(s.BaseDNs)
+}
+
+// validateBaseDNs rejects base DNs that are malformed or overlap, as the
+// subtrees searched under them would report the same entries twice. The
+// empty DN is the root of every other.
+func validateBaseDNs(baseDNs []string) error {
+	dns := make([]*ldap.DN, len(baseDNs))
+	for i, v := range baseDNs {
+		dn, err := ldap.ParseDN(v)
+		if err != nil {
+			return fmt.Errorf("invalid base DN %q: %s", v, err)
+		}
+		dns[i] = dn
+	}
+	for i := range dns {
+		for j := range i {
+			if dns[i].EqualFold(dns[j]) || dns[i].AncestorOfFold(dns[j]) || dns[j].AncestorOfFold(dns[i]) {
+				return fmt.Errorf("base DNs %q and %q overlap", baseDNs[j], baseDNs[i])
+			}
+		}
+	}
+	return nil
+}
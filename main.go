@@ -1,13 +1,20 @@
 package main
 
 import (
+	"fmt"
+	"log/slog"
+	"maps"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/prometheus/client_golang/prometheus"
 	versioncollector "github.com/prometheus/client_golang/prometheus/collectors/version"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	promconfig "github.com/prometheus/common/config"
 	"github.com/prometheus/common/promslog"
 	"github.com/prometheus/common/promslog/flag"
 	"github.com/prometheus/common/version"
@@ -15,35 +22,90 @@ import (
 	"github.com/prometheus/exporter-toolkit/web/kingpinflag"
 
 	"github.com/wfrank/ibmslapd_exporter/collector"
+	"github.com/wfrank/ibmslapd_exporter/config"
 )
 
 var (
-	metricsEndpoint = kingpin.Flag("telemetry.endpoint", "Path under which to expose metrics.").Default("/metrics").String()
-	toolkitFlags    = kingpinflag.AddFlags(kingpin.CommandLine, ":9981")
+	configFile               = kingpin.Flag("config.file", "Path to the YAML file defining the modules.").String()
+	metricsEndpoint          = kingpin.Flag("telemetry.endpoint", "Path under which to expose metrics.").Default("/metrics").String()
+	timeoutOffset            = kingpin.Flag("timeout_offset", "Offset to subtract from the timeout sent by Prometheus, to leave time to send the results.").Default("0.5s").Duration()
+	disableDefaultCollectors = kingpin.Flag("collector.disable-defaults", "Set all collectors to disabled by default.").Default("false").Bool()
+	toolkitFlags             = kingpinflag.AddFlags(kingpin.CommandLine, ":9981")
 )
 
 func main() {
-	exporterConfig := &collector.Config{
-		LdapURI: kingpin.Flag("ldap_uri", "URI referring to the ldap server, only the protocol/host/port fields are allowed.").Default("ldap://localhost:389").String(),
-		BindDn:  kingpin.Flag("bind_dn", "Binding DN to authenticate the LDAP connections.").Default("cn=root").String(),
-		BindPw:  kingpin.Flag("bind_pw", "Password of the Binding DN.").String(),
-	}
+	ldapURI := kingpin.Flag("ldap_uri", "URI referring to the ldap server, only the protocol/host/port fields are allowed.").Default("ldap://localhost:389").String()
+
+	defaultModule := config.DefaultModule
+	kingpin.Flag("auth_method", "Method to authenticate the LDAP connections, one of: none, simple, external, digest-md5.").Default("simple").EnumVar(&defaultModule.Auth.Method, config.AuthMethods...)
+	kingpin.Flag("bind_dn", "Binding DN to authenticate the LDAP connections, or the SASL user name for digest-md5.").Default("cn=root").StringVar(&defaultModule.Auth.BindDn)
+	kingpin.Flag("bind_pw", "Password of the Binding DN.").Envar("IBMSLAPD_BIND_PW").StringVar((*string)(&defaultModule.Auth.BindPw))
+	kingpin.Flag("bind_pw_file", "File containing the password of the Binding DN, read on every scrape.").StringVar(&defaultModule.Auth.BindPwFile)
+	kingpin.Flag("tls.ca_file", "CA certificate file to verify the LDAP server certificate.").StringVar(&defaultModule.TLSConfig.CAFile)
+	kingpin.Flag("tls.cert_file", "Client certificate file for mutual TLS.").StringVar(&defaultModule.TLSConfig.CertFile)
+	kingpin.Flag("tls.key_file", "Client key file for mutual TLS.").StringVar(&defaultModule.TLSConfig.KeyFile)
+	kingpin.Flag("tls.server_name", "Server name to verify the LDAP server certificate against, defaults to the host of the URI.").StringVar(&defaultModule.TLSConfig.ServerName)
+	kingpin.Flag("tls.insecure_skip_verify", "Skip verification of the LDAP server certificate.").BoolVar(&defaultModule.TLSConfig.InsecureSkipVerify)
+	tlsMinVersion := kingpin.Flag("tls.min_version", "Minimum TLS version to accept, one of: TLS10, TLS11, TLS12, TLS13.").Enum("TLS10", "TLS11", "TLS12", "TLS13")
+	kingpin.Flag("start_tls", "Upgrade ldap:// connections with StartTLS.").BoolVar(&defaultModule.StartTLS)
+	kingpin.Flag("require_tls", "Refuse to bind over an unencrypted connection.").BoolVar(&defaultModule.RequireTLS)
+	kingpin.Flag("ldap_timeout", "Timeout of each LDAP operation, including dialing the server.").Default("1s").DurationVar(&defaultModule.Timeout)
+	kingpin.Flag("scrape_timeout", "Timeout of a whole scrape, 0 to only follow the timeout sent by Prometheus.").Default("0s").DurationVar(&defaultModule.ScrapeTimeout)
+	kingpin.Flag("session.persistent", "Keep an authenticated LDAP session open across scrapes instead of dialing on every scrape.").BoolVar(&defaultModule.Session.Persistent)
+	kingpin.Flag("session.idle_timeout", "Close the persistent session when it has not been used for this long.").Default("5m").DurationVar(&defaultModule.Session.IdleTimeout)
+	kingpin.Flag("session.max_backoff", "Maximum delay between attempts to reestablish the persistent session.").Default("1m").DurationVar(&defaultModule.Session.MaxBackoff)
 
 	promslogConfig := &promslog.Config{}
 	flag.AddFlags(kingpin.CommandLine, promslogConfig)
 	kingpin.HelpFlag.Short('h')
 	kingpin.Version(version.Print("ibmslapd_exporter"))
 	kingpin.Parse()
+	defaultModule.TLSConfig.MinVersion = promconfig.TLSVersions[*tlsMinVersion]
+	if *disableDefaultCollectors {
+		collector.DisableDefaultCollectors()
+	}
 
 	logger := promslog.New(promslogConfig)
 
-	exporter := collector.NewExporter(logger, exporterConfig)
-	prometheus.MustRegister(exporter)
+	sc := &config.SafeConfig{}
+	if err := reloadConfig(logger, sc, defaultModule); err != nil {
+		logger.Error("Error loading config", "err", err)
+		os.Exit(1)
+	}
+	if *configFile != "" {
+		logger.Info("Loaded config file", "file", *configFile)
+	}
+
+	state := collector.NewState()
 	prometheus.MustRegister(versioncollector.NewCollector("ibmslapd_exporter"))
 
 	logger.Info("Starting ibmslapd_exporter", "version", version.Info())
 	logger.Info("Build context", "build", version.BuildContext())
-	logger.Info("Collect metrics from", "ldap_uri", *exporterConfig.LdapURI)
+	logger.Info("Collect metrics from", "ldap_uri", *ldapURI)
+
+	reloadCh := make(chan chan error)
+	go func() {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		for {
+			select {
+			case <-hup:
+				if err := reloadConfig(logger, sc, defaultModule); err != nil {
+					logger.Error("Error reloading config", "err", err)
+					continue
+				}
+				logger.Info("Reloaded config file")
+			case rc := <-reloadCh:
+				if err := reloadConfig(logger, sc, defaultModule); err != nil {
+					logger.Error("Error reloading config", "err", err)
+					rc <- err
+				} else {
+					logger.Info("Reloaded config file")
+					rc <- nil
+				}
+			}
+		}
+	}()
 
 	landingConfig := web.LandingConfig{
 		Name:        "ibmslapd exporter",
@@ -62,10 +124,49 @@ func main() {
 		os.Exit(1)
 	}
 	http.Handle("/", landingPage)
-	http.Handle(*metricsEndpoint, promhttp.Handler())
+	http.Handle(*metricsEndpoint, promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			metricsHandler(w, r, logger, sc, state, *ldapURI)
+		})))
+	http.HandleFunc("/probe", func(w http.ResponseWriter, r *http.Request) {
+		probeHandler(w, r, logger, sc, state)
+	})
+	http.HandleFunc("/-/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			fmt.Fprintf(w, "This endpoint requires a POST request.\n")
+			return
+		}
+		rc := make(chan error)
+		reloadCh <- rc
+		if err := <-rc; err != nil {
+			http.Error(w, fmt.Sprintf("failed to reload config: %s", err), http.StatusInternalServerError)
+		}
+	})
 	server := &http.Server{}
 	if err := web.ListenAndServe(server, toolkitFlags, logger); err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
 	}
 }
+
+// reloadConfig loads the config file, if any, and swaps it in once it has
+// been validated. The flags make up the module named "default" unless the
+// file defines its own.
+func reloadConfig(logger *slog.Logger, sc *config.SafeConfig, defaultModule config.Module) error {
+	c, err := config.LoadFile(*configFile, defaultModule)
+	if err != nil {
+		return err
+	}
+	if err := collector.ValidateConfig(c); err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Modules)) {
+		auth := c.Modules[name].Auth
+		if pw, err := auth.Password(); err == nil && auth.Method == "simple" && (auth.BindDn == "" || pw == "") {
+			logger.Warn("No bind DN or password configured, querying anonymously", "module", name, "bind_dn", auth.BindDn)
+		}
+	}
+	sc.Set(c)
+	return nil
+}
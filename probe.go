@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/wfrank/ibmslapd_exporter/collector"
+	"github.com/wfrank/ibmslapd_exporter/config"
+)
+
+// probeHandler scrapes the LDAP server given in the target parameter with a
+// fresh registry, so that a single exporter can serve a fleet of servers.
+func probeHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, sc *config.SafeConfig, state *collector.State) {
+	params := r.URL.Query()
+	target := params.Get("target")
+	if target == "" {
+		http.Error(w, "Target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	if !strings.Contains(target, "://") {
+		target = "ldap://" + target
+	}
+
+	// The module must be given explicitly, so that the credentials of the
+	// default module are not sent to any target a caller asks for.
+	moduleName := params.Get("module")
+	if moduleName == "" {
+		http.Error(w, "Module parameter is missing", http.StatusBadRequest)
+		return
+	}
+	module, ok := sc.Module(moduleName)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown module %q", moduleName), http.StatusBadRequest)
+		return
+	}
+	// Nor is a password sent over a connection anyone on the way can read.
+	// Without a password the query is anonymous and not held to RequireTLS.
+	if module.Auth.Method == "simple" {
+		module.RequireTLS = true
+	}
+	logger = logger.With("target", target, "module", moduleName)
+
+	ctx, cancel, err := scrapeContext(r, module)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to parse timeout from Prometheus header: %s", err), http.StatusInternalServerError)
+		return
+	}
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector.NewExporter(ctx, logger, state, target, moduleName, module))
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// metricsHandler scrapes the server given by --ldap_uri along with the
+// exporter's own metrics. The exporter is built on every request so that it
+// picks up reloaded modules and the scrape timeout of the request.
+func metricsHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, sc *config.SafeConfig, state *collector.State, ldapURI string) {
+	module, _ := sc.Module("default")
+
+	ctx, cancel, err := scrapeContext(r, module)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to parse timeout from Prometheus header: %s", err), http.StatusInternalServerError)
+		return
+	}
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector.NewExporter(ctx, logger, state, ldapURI, "default", module))
+	gatherers := prometheus.Gatherers{prometheus.DefaultGatherer, registry}
+	promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// scrapeContext returns the context of a scrape, which ends after the
+// timeout returned by scrapeTimeout.
+func scrapeContext(r *http.Request, module *config.Module) (context.Context, context.CancelFunc, error) {
+	timeout, err := scrapeTimeout(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), module.ScrapeTimeout, *timeoutOffset)
+	if err != nil {
+		return nil, nil, err
+	}
+	if timeout == 0 {
+		ctx, cancel := context.WithCancel(r.Context())
+		return ctx, cancel, nil
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	return ctx, cancel, nil
+}
+
+// scrapeTimeout returns the scrape timeout of the module or, if Prometheus
+// sent its own scrape timeout in header, that timeout less offset, whichever
+// is shorter. If the offset leaves no time, the timeout of Prometheus is used
+// as it is. 0 means no timeout.
+func scrapeTimeout(header string, moduleTimeout, offset time.Duration) (time.Duration, error) {
+	timeout := moduleTimeout
+	if header == "" {
+		return timeout, nil
+	}
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil {
+		return 0, err
+	}
+	t := time.Duration(seconds * float64(time.Second))
+	if t-offset > 0 {
+		t -= offset
+	}
+	if t > 0 && (timeout == 0 || t < timeout) {
+		timeout = t
+	}
+	return timeout, nil
+}
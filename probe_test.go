@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScrapeTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		header        string
+		moduleTimeout time.Duration
+		offset        time.Duration
+		want          time.Duration
+		err           bool
+	}{
+		{name: "no timeout", want: 0},
+		{name: "module only", moduleTimeout: 5 * time.Second, want: 5 * time.Second},
+		{name: "header less offset", header: "10", offset: 500 * time.Millisecond, want: 9500 * time.Millisecond},
+		{name: "shorter module", header: "10", moduleTimeout: 3 * time.Second, offset: 500 * time.Millisecond, want: 3 * time.Second},
+		{name: "shorter header", header: "2", moduleTimeout: 3 * time.Second, offset: 500 * time.Millisecond, want: 1500 * time.Millisecond},
+		{name: "offset equal to header", header: "0.5", offset: 500 * time.Millisecond, want: 500 * time.Millisecond},
+		{name: "offset exceeding header", header: "0.3", offset: 500 * time.Millisecond, want: 300 * time.Millisecond},
+		{name: "offset exceeding header with module", header: "0.3", moduleTimeout: 5 * time.Second, offset: time.Second, want: 300 * time.Millisecond},
+		{name: "negative offset", header: "1", offset: -time.Second, want: 2 * time.Second},
+		{name: "zero header", header: "0", moduleTimeout: 5 * time.Second, offset: 500 * time.Millisecond, want: 5 * time.Second},
+		{name: "malformed header", header: "ten", err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := scrapeTimeout(tc.header, tc.moduleTimeout, tc.offset)
+			if (err != nil) != tc.err || got != tc.want {
+				t.Errorf("scrapeTimeout(%q, %s, %s) = %s, %v, want %s", tc.header, tc.moduleTimeout, tc.offset, got, err, tc.want)
+			}
+		})
+	}
+}